@@ -1,17 +1,105 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"partial-git/internal"
+	"partial-git/internal/repository"
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 type flags struct {
-	Set   string
-	Auth  bool
-	Check bool
-	Unset bool
+	Host      string
+	Set       string
+	Auth      bool
+	Check     bool
+	Unset     bool
+	Listing   string
+	Mode      string
+	Jobs      int
+	APIJobs   int
+	KeepGoing bool
+	Retries   int
+	WaitLimit bool
+
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+	StallTimeout   time.Duration
+
+	Output          string
+	StripComponents int
+	Flat            bool
+
+	Overwrite      bool
+	SkipExisting   bool
+	FailOnExisting bool
+	Update         bool
+
+	Submodules string
+	Ref        string
+	FromFile   string
+
+	Include     []string
+	Exclude     []string
+	MaxFileSize string
+	Patterns    string
+	Cone        bool
+
+	DryRun bool
+	Format string
+	Quiet  bool
+
+	Storage  string
+	ClientID string
+	Scopes   []string
+
+	AppID          int64
+	InstallationID int64
+	AppKey         string
 }
 
 func cmdFlags(c *cobra.Command, f *flags) {
-	c.Flags().StringVarP(&f.Set, "set", "s", "", "store GitHub Personal Access Token in shell profile")
+	c.Flags().StringVarP(&f.Set, "set", "s", "", "store a GitHub Personal Access Token (in the system keyring when available, else an encrypted file)")
 	c.Flags().BoolVarP(&f.Auth, "auth", "a", false, "show authenticated user information")
-	c.Flags().BoolVarP(&f.Check, "check", "c", false, "check token status and availability")
-	c.Flags().BoolVarP(&f.Unset, "unset", "u", false, "remove GitHub token from shell profile")
+	c.Flags().BoolVarP(&f.Check, "check", "c", false, "check token status, scopes and expiry; with a URL, also test access to that repository")
+	c.Flags().BoolVarP(&f.Unset, "unset", "u", false, "remove the stored GitHub token")
+	c.Flags().StringVar(&f.Storage, "storage", "", "where --set keeps the token: keyring, file (passphrase-encrypted) or profile (plaintext shell profile, legacy)")
+	c.Flags().StringVar(&f.FromFile, "from-file", "", "download every target listed in a spec file (one \"<url> [-> <destination>]\" per line)")
+	// -o/--output is the output directory, so the report format gets its own name.
+	c.PersistentFlags().StringVar(&f.Format, "format", internal.FormatText, "how to report results: text, or json (newline-delimited events for downloads)")
+	c.Flags().StringVar(&f.Host, "host", "", "GitHub Enterprise Server host that --set, --unset, --auth and --check apply to (default github.com)")
+	c.Flags().BoolVar(&f.Overwrite, "overwrite", false, "replace files that already exist locally (default)")
+	c.Flags().BoolVar(&f.SkipExisting, "skip-existing", false, "leave files that already exist locally untouched")
+	c.Flags().BoolVar(&f.FailOnExisting, "fail-on-existing", false, "treat a file that already exists locally as an error")
+	c.Flags().BoolVar(&f.Update, "update", false, "only re-download files whose content differs from the local copy")
+	c.Flags().BoolVarP(&f.DryRun, "dry-run", "n", false, "list what would be downloaded without writing anything (same as pgit ls)")
+	downloadFlags(c, f)
+}
+
+// downloadFlags are shared by every command that downloads files.
+func downloadFlags(c *cobra.Command, f *flags) {
+	c.Flags().StringVar(&f.Ref, "ref", "", "branch, tag or commit SHA to download, overriding the one in the URL")
+	c.Flags().StringVar(&f.Listing, "listing", "trees", "how to list repository files: trees (one API call) or contents (one call per directory)")
+	c.Flags().StringVar(&f.Mode, "mode", "auto", "how to fetch files: auto, files (one request per file) or archive (stream the repository tarball)")
+	c.Flags().IntVarP(&f.Jobs, "jobs", "j", repository.DefaultJobs, "number of files to download concurrently")
+	c.Flags().IntVar(&f.APIJobs, "api-jobs", repository.DefaultListJobs, "number of concurrent GitHub API listing calls")
+	c.Flags().BoolVarP(&f.Quiet, "quiet", "q", false, "print nothing but errors: no progress, file names or summary")
+	c.Flags().BoolVarP(&f.KeepGoing, "keep-going", "k", false, "keep downloading after a file fails and report every failure at the end")
+	c.Flags().IntVar(&f.Retries, "retries", repository.DefaultRetries, "number of times to retry a request after a transient failure")
+	c.Flags().BoolVar(&f.WaitLimit, "wait-for-rate-limit", false, "sleep until the GitHub rate limit resets instead of failing")
+	c.Flags().DurationVar(&f.Timeout, "timeout", 0, "overall download deadline, e.g. 5m (0 means no deadline)")
+	c.Flags().DurationVar(&f.RequestTimeout, "request-timeout", repository.DefaultRequestTimeout, "how long to wait for each response to start (0 means no limit)")
+	c.Flags().DurationVar(&f.StallTimeout, "stall-timeout", repository.DefaultStallTimeout, "fail a transfer that receives no data for this long (0 disables)")
+	c.Flags().StringVarP(&f.Output, "output", "o", "", "directory to write downloaded files into (default: current directory)")
+	c.Flags().IntVar(&f.StripComponents, "strip-components", 0, "strip N leading components from local paths, like tar")
+	c.Flags().BoolVar(&f.Flat, "flat", false, "write every file directly into the output directory")
+	c.Flags().StringArrayVar(&f.Include, "include", nil, "only download paths matching this glob (repeatable; ** matches any depth)")
+	c.Flags().StringArrayVar(&f.Exclude, "exclude", nil, "skip paths matching this glob (repeatable; excluded directories are never listed)")
+	c.Flags().StringVar(&f.MaxFileSize, "max-file-size", "", "skip files larger than this, e.g. 500K or 10MB")
+	c.Flags().StringVar(&f.Patterns, "patterns", "", "select paths with a gitignore-syntax pattern file such as .pgit, as git sparse-checkout does")
+	c.Flags().BoolVar(&f.Cone, "cone", false, "read --patterns as a list of directories (sparse-checkout cone mode)")
+	c.Flags().Int64Var(&f.AppID, "app-id", 0, "authenticate as this GitHub App instead of with a token (needs --installation-id and --app-key)")
+	c.Flags().Int64Var(&f.InstallationID, "installation-id", 0, "ID of the GitHub App installation to act as")
+	c.Flags().StringVar(&f.AppKey, "app-key", "", "the GitHub App's private key (PEM file)")
+	c.Flags().StringVar(&f.Submodules, "submodules", "skip", "what to do with submodules: skip, record (empty directory) or fetch (download the pinned commit)")
 }
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"partial-git/internal"
+
+	"github.com/spf13/cobra"
+)
+
+func loginCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "login",
+		Short: "Sign in to GitHub in the browser and store the token",
+		Long: `Sign in with GitHub's OAuth device flow instead of creating a token by
+hand: pgit shows a one-time code, you enter it at the address it prints, and
+the token GitHub issues is stored as --set would store it.
+
+The OAuth app is taken from --client-id, then ` + internal.OAuthClientIDEnv + `,
+then the one built into pgit for github.com. It must have device flow
+enabled; Enterprise Server needs an app registered on that instance.
+
+Examples:
+  pgit login
+  pgit login --host ghe.example.com --client-id 0123456789abcdef0123`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.Login(cmd.Context(), internalFlags())
+		},
+	}
+
+	oauthFlags(c, &f)
+	c.Flags().StringVar(&f.Storage, "storage", "", "where to keep the token: keyring, file (passphrase-encrypted) or profile (plaintext shell profile, legacy)")
+	c.Flags().StringSliceVar(&f.Scopes, "scopes", []string{"repo"}, "OAuth scopes to request; repo is needed for private repositories")
+	return c
+}
+
+func logoutCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "logout",
+		Short: "Remove the token pgit stored (it is not revoked)",
+		Long: `Remove the token pgit stored for the host from every backend.
+
+logout does not revoke the token: GitHub only revokes an OAuth token through
+the API when given the app's client secret, which pgit does not ship with.
+Instead it prints the settings page where you can revoke the token yourself;
+until you do, the token stays valid. Tokens pgit borrows from elsewhere, such
+as the gh CLI, are left alone.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.Logout(internalFlags())
+		},
+	}
+
+	oauthFlags(c, &f)
+	return c
+}
+
+func oauthFlags(c *cobra.Command, f *flags) {
+	c.Flags().StringVar(&f.Host, "host", "", "GitHub Enterprise Server host to sign in to (default github.com)")
+	c.Flags().StringVar(&f.ClientID, "client-id", "", "client ID of the OAuth app to sign in with")
+}
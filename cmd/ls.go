@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"partial-git/internal"
+
+	"github.com/spf13/cobra"
+)
+
+func lsCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "ls <github-url>",
+		Short: "List the files a download would fetch without writing anything",
+		Long: `List every file a download of the URL would fetch, with its type, size
+and blob SHA, followed by the file count, total size and the number of GitHub
+API calls the listing took. Nothing is written to disk. Filters such as
+--include and --patterns apply exactly as they would to the download.
+
+Examples:
+  pgit ls https://github.com/owner/repo/tree/main/docs
+  pgit ls --listing contents --exclude '*.png' owner/repo:assets`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := validateDownloadFlags(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+
+			internal.List(cmd.Context(), internalFlags(), args[0])
+		},
+	}
+
+	downloadFlags(c, &f)
+	return c
+}
@@ -17,18 +17,25 @@ var rootCmd = &cobra.Command{
 	Long: `pgit is a tool for downloading files or folders from GitHub repositories.
 
 Usage:
-  pgit <github-url>           Download from a GitHub repository
+  pgit <github-url>...        Download from one or more GitHub repositories
+  pgit --from-file <spec>     Download every "<url> -> <destination>" listed in a file
   pgit --set <token>          Set GitHub Personal Access Token
   pgit --auth                 Show authenticated user information
-  pgit --check                Check token status and rate limits
+  pgit --check [github-url]   Check token status and rate limits, and access to a repository
   pgit --unset                Remove stored GitHub token
+  pgit sync <github-url>      Download or update a directory tracked by a manifest
+  pgit ls <github-url>        List what would be downloaded, and its API cost
+  pgit login                  Sign in to GitHub in the browser
+  pgit logout                 Revoke and remove the stored token
 
 Examples:
   pgit https://github.com/owner/repo
   pgit https://github.com/owner/repo/tree/main/src
+  pgit owner/repo@v1.2.0:docs
   pgit --set ghp_your_token_here
   pgit --auth
-  pgit --check`,
+  pgit --check
+  pgit --app-id 1 --installation-id 2 --app-key app.pem owner/repo`,
 	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := cmd.Context()
@@ -38,17 +45,62 @@ Examples:
 			return
 		}
 
-		internalFlags := internal.Flags{
-			Set:   f.Set,
-			Auth:  f.Auth,
-			Check: f.Check,
-			Unset: f.Unset,
-		}
-
-		internal.Run(ctx, internalFlags, args)
+		internal.Run(ctx, internalFlags(), args)
 	},
 }
 
+func internalFlags() internal.Flags {
+	return internal.Flags{
+		Host:      f.Host,
+		Set:       f.Set,
+		Auth:      f.Auth,
+		Check:     f.Check,
+		Unset:     f.Unset,
+		Listing:   f.Listing,
+		Mode:      f.Mode,
+		Jobs:      f.Jobs,
+		APIJobs:   f.APIJobs,
+		KeepGoing: f.KeepGoing,
+		Retries:   f.Retries,
+		WaitLimit: f.WaitLimit,
+
+		Timeout:        f.Timeout,
+		RequestTimeout: f.RequestTimeout,
+		StallTimeout:   f.StallTimeout,
+
+		Output:          f.Output,
+		StripComponents: f.StripComponents,
+		Flat:            f.Flat,
+
+		Overwrite:      f.Overwrite,
+		SkipExisting:   f.SkipExisting,
+		FailOnExisting: f.FailOnExisting,
+		Update:         f.Update,
+
+		Submodules: f.Submodules,
+		Ref:        f.Ref,
+		FromFile:   f.FromFile,
+
+		Include:     f.Include,
+		Exclude:     f.Exclude,
+		MaxFileSize: f.MaxFileSize,
+		Patterns:    f.Patterns,
+		Cone:        f.Cone,
+
+		DryRun: f.DryRun,
+		Format: f.Format,
+		Quiet:  f.Quiet,
+
+		Storage:  f.Storage,
+		ClientID: f.ClientID,
+		Scopes:   f.Scopes,
+
+		AppID:          f.AppID,
+		InstallationID: f.InstallationID,
+		AppKey:         f.AppKey,
+	}
+}
+
 func validateFlags(args []string) error {
 	flagCount := 0
 	if f.Auth {
@@ -68,6 +120,24 @@ func validateFlags(args []string) error {
 		return fmt.Errorf("only one of --set, --auth, --check, or --unset can be used at a time")
 	}
 
+	if err := validateDownloadFlags(); err != nil {
+		return err
+	}
+
+	policyCount := 0
+	for _, set := range []bool{f.Overwrite, f.SkipExisting, f.FailOnExisting, f.Update} {
+		if set {
+			policyCount++
+		}
+	}
+	if policyCount > 1 {
+		return fmt.Errorf("only one of --overwrite, --skip-existing, --fail-on-existing, or --update can be used at a time")
+	}
+
+	if f.Storage != "" && f.Set == "" {
+		return fmt.Errorf("--storage can only be used with --set")
+	}
+
 	switch {
 	case f.Set != "":
 		if err := token.ValidateToken(f.Set); err != nil {
@@ -78,25 +148,70 @@ func validateFlags(args []string) error {
 		}
 		return nil
 
-	case f.Auth || f.Check || f.Unset:
+	case f.Check:
+		if len(args) > 1 {
+			return fmt.Errorf("--check takes at most one GitHub URL to test access to")
+		}
+		return nil
+
+	case f.Auth || f.Unset:
 		if len(args) > 0 {
-			return fmt.Errorf("no arguments expected when using --auth, --check, or --unset")
+			return fmt.Errorf("no arguments expected when using --auth or --unset")
 		}
 		return nil
 
 	default:
-		if len(args) == 0 {
+		if len(args) == 0 && f.FromFile == "" {
 			return fmt.Errorf("GitHub URL is required for download operations")
 		}
-		if len(args) > 1 {
-			return fmt.Errorf("only one GitHub URL is allowed, got %d URLs", len(args))
+		if f.Ref != "" && (len(args) > 1 || f.FromFile != "") {
+			return fmt.Errorf("--ref applies to a single URL; put the ref in each URL instead (owner/repo@ref)")
+		}
+		if f.DryRun && (len(args) > 1 || f.FromFile != "") {
+			return fmt.Errorf("--dry-run lists a single URL")
 		}
 		return nil
 	}
 }
 
+func validateDownloadFlags() error {
+	if f.Format != internal.FormatText && f.Format != internal.FormatJSON {
+		return fmt.Errorf("--format must be text or json, got %q", f.Format)
+	}
+	if f.Jobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1, got %d", f.Jobs)
+	}
+	if f.APIJobs < 1 {
+		return fmt.Errorf("--api-jobs must be at least 1, got %d", f.APIJobs)
+	}
+	if f.Retries < 0 {
+		return fmt.Errorf("--retries cannot be negative, got %d", f.Retries)
+	}
+	if f.Timeout < 0 || f.RequestTimeout < 0 || f.StallTimeout < 0 {
+		return fmt.Errorf("timeouts cannot be negative")
+	}
+	if f.StripComponents < 0 {
+		return fmt.Errorf("--strip-components cannot be negative, got %d", f.StripComponents)
+	}
+	if f.Flat && f.StripComponents > 0 {
+		return fmt.Errorf("--flat and --strip-components cannot be used together")
+	}
+	if f.Cone && f.Patterns == "" {
+		return fmt.Errorf("--cone requires --patterns")
+	}
+	if (f.AppID != 0 || f.InstallationID != 0 || f.AppKey != "") && (f.AppID <= 0 || f.InstallationID <= 0 || f.AppKey == "") {
+		return fmt.Errorf("--app-id, --installation-id and --app-key must be used together")
+	}
+
+	return nil
+}
+
 func Execute(ctx context.Context) error {
 	cmdFlags(rootCmd, &f)
 	rootCmd.AddCommand(versionCmd())
+	rootCmd.AddCommand(syncCmd())
+	rootCmd.AddCommand(lsCmd())
+	rootCmd.AddCommand(loginCmd())
+	rootCmd.AddCommand(logoutCmd())
 	return rootCmd.ExecuteContext(ctx)
 }
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"partial-git/internal"
+
+	"github.com/spf13/cobra"
+)
+
+func syncCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "sync <github-url>",
+		Short: "Download a directory and keep it in sync with upstream",
+		Long: `Download a directory and record what was fetched in a manifest
+(` + "`.pgit-manifest.json`" + ` in the output directory). Later runs re-download only
+files whose content changed, delete files that were removed upstream, and
+print a summary of what changed.
+
+Examples:
+  pgit sync -o third_party/protos https://github.com/owner/repo/tree/main/protos`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := validateDownloadFlags(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+
+			internal.Sync(cmd.Context(), internalFlags(), args[0])
+		},
+	}
+
+	downloadFlags(c, &f)
+	return c
+}
@@ -5,6 +5,7 @@ go 1.23.5
 require (
 	github.com/google/go-github/v57 v57.0.0
 	github.com/spf13/cobra v1.9.1
+	golang.org/x/crypto v0.36.0
 	golang.org/x/oauth2 v0.30.0
 )
 
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"partial-git/internal/repository"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// useAppAuth switches every API client to the GitHub App installation named
+// by --app-id, --installation-id and --app-key, if given.
+func useAppAuth(flags Flags) *repository.AppAuth {
+	if flags.AppID == 0 {
+		return nil
+	}
+
+	app, err := repository.NewAppAuth(flags.AppID, flags.InstallationID, flags.AppKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	repository.UseAppAuth(app)
+	return app
+}
+
+func appStorage(app *repository.AppAuth) string {
+	return fmt.Sprintf("GitHub App %d, installation %d", app.AppID, app.InstallationID)
+}
+
+func showAppInfo(ctx context.Context, app *repository.AppAuth, host repository.Host) {
+	fmt.Printf("Authenticating as %s\n", appStorage(app))
+
+	info, err := app.Describe(ctx, host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ App: %s (%s)\n", info.App.GetName(), info.App.GetSlug())
+	installation := info.Installation
+	fmt.Printf("✓ Installed on: %s (%s)\n", installation.GetAccount().GetLogin(), installation.GetTargetType())
+	fmt.Printf("✓ Repositories: %s\n", installation.GetRepositorySelection())
+	if permissions := appPermissions(installation.GetPermissions()); len(permissions) > 0 {
+		fmt.Printf("✓ Permissions: %s\n", strings.Join(permissions, ", "))
+	}
+
+	// Minting a token proves the installation is usable, not just visible.
+	if _, err := hostClient(host).GetRateLimit(ctx); err != nil {
+		fmt.Printf("⚠️  Warning: Could not use an installation token: %v\n", err)
+	}
+}
+
+func showAppInfoJSON(ctx context.Context, app *repository.AppAuth, host repository.Host) {
+	out := authJSON{Host: host.Name, Token: tokenJSON{Found: true, Storage: appStorage(app)}}
+	info, err := app.Describe(ctx, host)
+	if err != nil {
+		out.Error = err.Error()
+		printJSON(out)
+		os.Exit(1)
+	}
+
+	installation := info.Installation
+	out.App = &appJSON{
+		ID:                  info.App.GetID(),
+		Slug:                info.App.GetSlug(),
+		Name:                info.App.GetName(),
+		InstallationID:      installation.GetID(),
+		Account:             installation.GetAccount().GetLogin(),
+		RepositorySelection: installation.GetRepositorySelection(),
+		Permissions:         appPermissions(installation.GetPermissions()),
+	}
+	printJSON(out)
+}
+
+// appPermissions lists an installation's permissions as "name:level",
+// sorted by name.
+func appPermissions(p *github.InstallationPermissions) []string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	var levels map[string]string
+	if err := json.Unmarshal(data, &levels); err != nil {
+		return nil
+	}
+
+	permissions := make([]string, 0, len(levels))
+	for name, level := range levels {
+		permissions = append(permissions, name+":"+level)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+	"time"
+)
+
+func runBatch(ctx context.Context, flags Flags, args []string) {
+	start := time.Now()
+
+	var targets []repository.Target
+	for _, arg := range args {
+		githubURL, err := parseGitHubURL(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing GitHub URL: %v\n", err)
+			os.Exit(1)
+		}
+		targets = append(targets, repository.Target{URL: githubURL})
+	}
+
+	if flags.FromFile != "" {
+		fromFile, err := repository.ReadTargets(flags.FromFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		targets = append(targets, fromFile...)
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s lists no targets\n", flags.FromFile)
+		os.Exit(1)
+	}
+
+	opts, err := downloadOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := repository.CheckDestinations(targets, opts.OutputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, target := range targets {
+		tokenManager := token.NewManagerForHost(target.URL.Host.Name)
+		if err := validateRuntimeConditions(ctx, flags, tokenManager, target.URL); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", target.URL.String(), err)
+			os.Exit(1)
+		}
+	}
+
+	if flags.Format == FormatJSON {
+		runBatchJSON(ctx, flags, targets, opts, start)
+		return
+	}
+
+	if !flags.Quiet {
+		fmt.Printf("Starting download of %d targets...\n", len(targets))
+		if flags.Output != "" {
+			fmt.Printf("Output: %s\n", flags.Output)
+		}
+	}
+
+	results, err := repository.DownloadBatch(ctx, targets, opts)
+	if !flags.Quiet || err != nil {
+		printBatchSummary(results)
+	}
+	if err != nil {
+		exitOnDownloadError(err, flags)
+	}
+	if !flags.Quiet {
+		fmt.Println("Download Completed")
+		fmt.Println(time.Since(start))
+	}
+}
+
+// runBatchJSON reports each target's files as they finish, then a "target"
+// summary per target and an overall "summary".
+func runBatchJSON(ctx context.Context, flags Flags, targets []repository.Target, opts repository.Options, start time.Time) {
+	opts = jsonOptions(opts, "")
+	for i := range targets {
+		targets[i].OnResult = jsonOptions(opts, targetName(targets[i])).OnResult
+	}
+
+	results, err := repository.DownloadBatch(ctx, targets, opts)
+
+	total := summaryEvent{Event: "summary", OutputDir: flags.Output, Targets: len(results)}
+	for _, tr := range results {
+		summary := newSummaryEvent("target", tr.Target.URL, tr.Result, tr.Err)
+		summary.Target = targetName(tr.Target)
+		summary.OutputDir = tr.Target.OutputDir
+		printJSON(summary)
+
+		total.Downloaded += summary.Downloaded
+		total.Skipped += summary.Skipped
+		total.Failed += summary.Failed
+		total.Bytes += summary.Bytes
+		total.APICalls += summary.APICalls
+		// Targets cancelled by another's failure are not failures themselves.
+		if tr.Err != nil && !errors.Is(tr.Err, context.Canceled) {
+			total.FailedTargets++
+		}
+	}
+
+	total.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		total.Error = err.Error()
+	}
+	printJSON(total)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// printBatchSummary prints one line per target followed by totals across the
+// whole batch.
+func printBatchSummary(results []repository.TargetResult) {
+	var downloaded, skipped int
+	var failed []string
+
+	fmt.Println("Targets:")
+	for _, tr := range results {
+		name := targetName(tr.Target)
+		if tr.Target.OutputDir != "" {
+			name += " → " + tr.Target.OutputDir
+		}
+
+		if tr.Result != nil {
+			downloaded += len(tr.Result.Downloaded())
+			skipped += len(tr.Result.Skipped())
+			for _, fr := range tr.Result.Failed() {
+				failed = append(failed, fmt.Sprintf("%s:%s: %v", targetName(tr.Target), displayPath(fr.Path), fr.Err))
+			}
+		}
+
+		switch {
+		case tr.Err == nil:
+			fmt.Printf("  ✓ %s (%d downloaded)\n", name, len(tr.Result.Downloaded()))
+		case tr.Result == nil || len(tr.Result.Failed()) == 0:
+			fmt.Printf("  ✗ %s: %v\n", name, tr.Err)
+			if tr.Result == nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", targetName(tr.Target), tr.Err))
+			}
+		default:
+			fmt.Printf("  ✗ %s (%d downloaded, %d failed)\n", name, len(tr.Result.Downloaded()), len(tr.Result.Failed()))
+		}
+	}
+
+	fmt.Printf("Downloaded: %d, Skipped: %d, Failed: %d\n", downloaded, skipped, len(failed))
+	if len(failed) > 0 {
+		fmt.Println("Failed paths:")
+		for _, line := range failed {
+			fmt.Printf("  ✗ %s\n", line)
+		}
+	}
+}
+
+func targetName(target repository.Target) string {
+	if target.URL.Path == "" {
+		return target.URL.String()
+	}
+	return target.URL.String() + "/" + target.URL.Path
+}
@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+	"slices"
+	"strings"
+	"time"
+)
+
+// expiryWarningPeriod is how close to its expiry date a token has to be
+// before --check warns about it.
+const expiryWarningPeriod = 7 * 24 * time.Hour
+
+// tokenDiagnosis is what --check learns about the token beyond its source.
+type tokenDiagnosis struct {
+	kind string
+	user string
+	// scopes is nil when GitHub reported none, as for fine-grained tokens.
+	scopes  []string
+	expires time.Time
+	err     error
+}
+
+func diagnoseToken(ctx context.Context, client *repository.GitHubClient, storedToken string) tokenDiagnosis {
+	diagnosis := tokenDiagnosis{kind: token.Kind(storedToken)}
+	info, err := client.GetAuthInfo(ctx)
+	if err != nil {
+		diagnosis.err = err
+		return diagnosis
+	}
+
+	diagnosis.user = info.User.GetLogin()
+	diagnosis.scopes = info.Scopes
+	diagnosis.expires = info.Expires
+	return diagnosis
+}
+
+func describeKind(kind string) string {
+	switch kind {
+	case token.KindClassic:
+		return "classic personal access token"
+	case token.KindFineGrained:
+		return "fine-grained personal access token"
+	case token.KindOAuth:
+		return "OAuth app token"
+	case token.KindAppUser:
+		return "GitHub App user token"
+	case token.KindAppInstallation:
+		return "GitHub App installation token"
+	default:
+		return "unrecognised token type"
+	}
+}
+
+// expiryWarning describes an expired token or one expiring soon, or returns
+// "" when there is nothing to warn about.
+func expiryWarning(expires, now time.Time) string {
+	switch left := expires.Sub(now); {
+	case expires.IsZero():
+		return ""
+	case left <= 0:
+		return fmt.Sprintf("token expired on %s", expires.Local().Format("2006-01-02 15:04"))
+	case left < expiryWarningPeriod:
+		return fmt.Sprintf("token expires in %s, on %s", formatDays(left), expires.Local().Format("2006-01-02 15:04"))
+	default:
+		return ""
+	}
+}
+
+func formatDays(d time.Duration) string {
+	if days := int(d.Hours() / 24); days >= 1 {
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return fmt.Sprintf("%d hours", int(d.Hours())+1)
+}
+
+func printTokenDiagnosis(d tokenDiagnosis) {
+	fmt.Printf("✓ Token type: %s\n", describeKind(d.kind))
+	if d.err != nil {
+		fmt.Printf("Warning: Could not get token details: %v\n", d.err)
+		return
+	}
+
+	fmt.Printf("✓ Authenticated as: %s\n", d.user)
+	switch {
+	case d.scopes != nil && len(d.scopes) == 0:
+		fmt.Println("⚠️  Token has no scopes: only public repositories can be read")
+	case d.scopes != nil:
+		fmt.Printf("✓ Scopes: %s\n", strings.Join(d.scopes, ", "))
+	case d.kind == token.KindFineGrained:
+		fmt.Println("✓ Scopes: none (fine-grained tokens grant per-repository permissions instead)")
+	}
+
+	if warning := expiryWarning(d.expires, time.Now()); warning != "" {
+		fmt.Printf("⚠️  Warning: %s\n", warning)
+	} else if !d.expires.IsZero() {
+		fmt.Printf("✓ Expires: %s\n", d.expires.Local().Format("2006-01-02 15:04"))
+	} else {
+		fmt.Println("✓ Expires: never")
+	}
+}
+
+// explainRepoAccess says why a repository lookup failed. GitHub answers 404
+// both for repositories that do not exist and for private ones the token
+// cannot see, so the explanation depends on what the token is.
+func explainRepoAccess(access *repository.RepoAccess, d *tokenDiagnosis, appAuth bool) string {
+	switch access.Status {
+	case http.StatusNotFound:
+	case http.StatusForbidden:
+		if access.SSO != "" {
+			return "the organization uses SAML single sign-on and the token is not authorized for it; authorize it in the token's settings"
+		}
+		return fmt.Sprintf("access denied: %v", access.Err)
+	case http.StatusUnauthorized:
+		return "the token was rejected: it is invalid, revoked or expired"
+	default:
+		return fmt.Sprintf("lookup failed: %v", access.Err)
+	}
+
+	if !access.OwnerFound {
+		return "not found: the owner does not exist, so check the URL for typos"
+	}
+
+	switch {
+	case appAuth:
+		return "not found, or the app installation has not been granted access to this repository"
+	case d == nil:
+		return "not found, or the repository is private and no token is configured"
+	case d.kind == token.KindFineGrained:
+		return "not found, or the token lacks access: fine-grained tokens only reach the repositories selected when they were created, under a single owner"
+	case d.scopes != nil && !slices.Contains(d.scopes, "repo"):
+		return "not found, or the repository is private and the token lacks the repo scope needed to read it"
+	default:
+		return "not found, or the repository is private and your account has not been given access"
+	}
+}
+
+func printRepoAccess(githubURL *repository.GitHubURL, access *repository.RepoAccess, d *tokenDiagnosis, appAuth bool) {
+	name := githubURL.Owner + "/" + githubURL.Repository
+	if access.Repository == nil {
+		fmt.Printf("✗ %s: %s\n", name, explainRepoAccess(access, d, appAuth))
+		return
+	}
+
+	visibility := "public"
+	if access.Repository.GetPrivate() {
+		visibility = "private"
+	}
+	fmt.Printf("✓ %s is accessible (%s, permission: %s)\n", name, visibility, repoPermission(access))
+}
+
+// repoPermission is the strongest permission GitHub reports for the
+// repository.
+func repoPermission(access *repository.RepoAccess) string {
+	permissions := access.Repository.GetPermissions()
+	for _, level := range []string{"admin", "maintain", "push", "triage", "pull"} {
+		if permissions[level] {
+			return level
+		}
+	}
+	return "read"
+}
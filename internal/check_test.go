@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiryWarning(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		expires time.Time
+		want    string
+	}{
+		{"no expiry", time.Time{}, ""},
+		{"expired", now.Add(-time.Hour), "token expired on"},
+		{"expires now", now, "token expired on"},
+		{"in hours", now.Add(90 * time.Minute), "token expires in 2 hours"},
+		{"in one day", now.Add(30 * time.Hour), "token expires in 1 day"},
+		{"inside the week", now.Add(6*24*time.Hour + time.Hour), "token expires in 6 days"},
+		{"a week away", now.Add(7 * 24 * time.Hour), ""},
+		{"far off", now.Add(90 * 24 * time.Hour), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expiryWarning(tt.expires, now)
+			if (tt.want == "") != (got == "") || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("expiryWarning = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainRepoAccess(t *testing.T) {
+	notFound := &repository.RepoAccess{Status: http.StatusNotFound, OwnerFound: true, Err: errors.New("404")}
+	classic := &tokenDiagnosis{kind: token.KindClassic, scopes: []string{"read:org"}}
+	tests := []struct {
+		name    string
+		access  *repository.RepoAccess
+		d       *tokenDiagnosis
+		appAuth bool
+		want    string
+	}{
+		{"owner missing", &repository.RepoAccess{Status: http.StatusNotFound}, classic, false, "the owner does not exist"},
+		{"classic without repo", notFound, classic, false, "lacks the repo scope"},
+		{"classic with repo", notFound, &tokenDiagnosis{kind: token.KindClassic, scopes: []string{"repo"}}, false, "your account has not been given access"},
+		{"fine-grained", notFound, &tokenDiagnosis{kind: token.KindFineGrained}, false, "fine-grained tokens only reach"},
+		{"no token", notFound, nil, false, "no token is configured"},
+		{"app auth", notFound, nil, true, "app installation has not been granted access"},
+		{"saml sso", &repository.RepoAccess{Status: http.StatusForbidden, SSO: "required; url=https://github.com/orgs/o/sso"}, classic, false, "SAML single sign-on"},
+		{"plain forbidden", &repository.RepoAccess{Status: http.StatusForbidden, Err: errors.New("forbidden")}, classic, false, "access denied: forbidden"},
+		{"rejected token", &repository.RepoAccess{Status: http.StatusUnauthorized}, classic, false, "the token was rejected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainRepoAccess(tt.access, tt.d, tt.appAuth); !strings.Contains(got, tt.want) {
+				t.Errorf("explainRepoAccess = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
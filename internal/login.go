@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+)
+
+const OAuthClientIDEnv = "PGIT_OAUTH_CLIENT_ID"
+
+// OAuthClientID is the OAuth app used for github.com when none is configured,
+// set at build time with -ldflags. The device flow needs no client secret,
+// which could not be kept secret in a distributed binary anyway. OAuth apps
+// are registered per instance, so Enterprise Server hosts always need their
+// own.
+var OAuthClientID string
+
+// oauthClientID returns the OAuth app to use for host: --client-id, then
+// PGIT_OAUTH_CLIENT_ID, then the built-in app.
+func oauthClientID(flags Flags, host repository.Host) string {
+	clientID := flags.ClientID
+	if clientID == "" {
+		clientID = os.Getenv(OAuthClientIDEnv)
+	}
+	if clientID == "" && !host.IsEnterprise() {
+		clientID = OAuthClientID
+	}
+	return clientID
+}
+
+// accountReport collects what an account command did and prints it as it
+// goes, or, with --format json, as a single accountJSON object at the end.
+type accountReport struct {
+	json bool
+	out  accountJSON
+}
+
+func newAccountReport(flags Flags, host repository.Host, action string) *accountReport {
+	return &accountReport{json: flags.Format == FormatJSON, out: accountJSON{Host: host.Name, Action: action}}
+}
+
+func (r *accountReport) printf(format string, args ...any) {
+	if !r.json {
+		fmt.Printf(format, args...)
+	}
+}
+
+// prompt shows instructions the user has to act on. They go to stderr with
+// --format json, to keep stdout parseable.
+func (r *accountReport) prompt(format string, args ...any) {
+	if r.json {
+		fmt.Fprintf(os.Stderr, format, args...)
+	} else {
+		fmt.Printf(format, args...)
+	}
+}
+
+func (r *accountReport) warn(format string, args ...any) {
+	if r.json {
+		r.out.Warnings = append(r.out.Warnings, fmt.Sprintf(format, args...))
+	} else {
+		fmt.Printf("⚠️  Warning: "+format+"\n", args...)
+	}
+}
+
+// fail reports err and exits. Prose goes to stderr prefixed with what.
+func (r *accountReport) fail(what string, err error) {
+	if r.json {
+		r.out.Error = err.Error()
+		printJSON(r.out)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", what, err)
+	}
+	os.Exit(1)
+}
+
+// done prints the JSON object, with the token pgit now reads for the host.
+func (r *accountReport) done(tokenManager *token.Manager) {
+	if !r.json {
+		return
+	}
+	current, err := tokenManager.GetToken()
+	r.out.Token = newTokenJSON(err == nil, tokenManager.GetStorageInfo(), current)
+	printJSON(r.out)
+}
+
+// SetToken stores the token given with --set.
+func SetToken(flags Flags, tokenManager *token.Manager, host repository.Host) {
+	report := newAccountReport(flags, host, "set")
+	if flags.Storage != "" {
+		if err := tokenManager.UseStorage(flags.Storage); err != nil {
+			report.fail("Error", err)
+		}
+	}
+	if err := tokenManager.SetToken(flags.Set); err != nil {
+		report.fail("Error setting GitHub token", err)
+	}
+
+	if current, err := tokenManager.GetToken(); err != nil || current != flags.Set {
+		report.warn("the token from %s takes precedence over the new one", tokenManager.GetStorageInfo())
+	}
+	report.printf("Storage backend: %s\n", tokenManager.GetStorageInfo())
+	report.done(tokenManager)
+}
+
+// UnsetToken deletes the token pgit stored for the host, for --unset.
+func UnsetToken(flags Flags, tokenManager *token.Manager, host repository.Host) {
+	report := newAccountReport(flags, host, "unset")
+	deleted, err := tokenManager.DeleteToken()
+	if err != nil {
+		report.fail("Error deleting GitHub token", err)
+	}
+	report.out.Deleted = deleted
+
+	if !deleted {
+		report.printf("No GitHub token found to delete\n")
+	}
+	warnRemainingToken(report, tokenManager)
+	report.done(tokenManager)
+}
+
+// warnRemainingToken points out a token pgit will still use after deleting
+// its own, such as one borrowed from the gh CLI.
+func warnRemainingToken(report *accountReport, tokenManager *token.Manager) {
+	if tokenManager.TokenExists() {
+		report.warn("a token is still available from %s", tokenManager.GetStorageInfo())
+	}
+}
+
+// Login signs in through the OAuth device flow and stores the token like
+// --set does.
+func Login(ctx context.Context, flags Flags) {
+	host, err := repository.ParseHost(flags.Host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report := newAccountReport(flags, host, "login")
+
+	clientID := oauthClientID(flags, host)
+	if clientID == "" {
+		fmt.Fprintf(os.Stderr, "Error: no OAuth app configured for %s\n", host.Name)
+		fmt.Fprintf(os.Stderr, "Register one with device flow enabled and pass --client-id or set %s\n", OAuthClientIDEnv)
+		os.Exit(1)
+	}
+
+	tokenManager := token.NewManagerForHost(host.Name)
+	if flags.Storage != "" {
+		if err := tokenManager.UseStorage(flags.Storage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	flow := repository.NewDeviceFlow(host, clientID, flags.Scopes)
+	code, err := flow.RequestCode(ctx)
+	if err != nil {
+		report.fail("Error", err)
+	}
+
+	report.prompt("First copy your one-time code: %s\n", code.UserCode)
+	report.prompt("Then open %s in your browser and enter it.\n", code.VerificationURI)
+	report.prompt("Waiting for authorization...\n")
+
+	accessToken, err := flow.Poll(ctx, code)
+	if err != nil {
+		report.fail("Error: login failed", err)
+	}
+
+	if err := tokenManager.SetToken(accessToken); err != nil {
+		report.fail("Error", err)
+	}
+
+	// A token in the environment, say, still wins over the new one.
+	if current, err := tokenManager.GetToken(); err != nil || current != accessToken {
+		report.warn("the token from %s takes precedence over the new one", tokenManager.GetStorageInfo())
+		report.done(tokenManager)
+		return
+	}
+
+	info, err := hostClient(host).GetAuthInfo(ctx)
+	if err != nil {
+		report.printf("✓ Logged in to %s\n", host.Name)
+		report.warn("could not get user info: %v", err)
+		report.done(tokenManager)
+		return
+	}
+	user := info.User
+	report.out.User = &userJSON{Login: user.GetLogin(), Name: user.GetName(), Email: user.GetEmail()}
+	report.printf("✓ Logged in to %s as %s\n", host.Name, user.GetLogin())
+	report.done(tokenManager)
+}
+
+// Logout removes the token pgit stored for the host and says where to revoke
+// it. Revoking an OAuth token through the API takes the app's client secret,
+// which pgit does not have. Tokens borrowed from other tools, such as the gh
+// CLI, are left alone.
+func Logout(flags Flags) {
+	host, err := repository.ParseHost(flags.Host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report := newAccountReport(flags, host, "logout")
+
+	tokenManager := token.NewManagerForHost(host.Name)
+	storedToken, err := tokenManager.StoredToken()
+	if err != nil {
+		report.printf("Not logged in to %s\n", host.Name)
+		report.done(tokenManager)
+		return
+	}
+
+	if _, err := tokenManager.DeleteToken(); err != nil {
+		report.fail("Error deleting GitHub token", err)
+	}
+	report.out.Deleted = true
+	report.out.RevokeURL = revokeURL(host, token.Kind(storedToken), oauthClientID(flags, host))
+
+	report.printf("The token is still valid until it is revoked at %s\n", report.out.RevokeURL)
+	warnRemainingToken(report, tokenManager)
+	report.done(tokenManager)
+}
+
+// revokeURL is the settings page where a token of the given kind can be
+// revoked.
+func revokeURL(host repository.Host, kind, clientID string) string {
+	switch {
+	case kind == token.KindOAuth && clientID != "":
+		return host.BaseURL + "settings/connections/applications/" + clientID
+	case kind == token.KindOAuth:
+		return host.BaseURL + "settings/applications"
+	case kind == token.KindFineGrained:
+		return host.BaseURL + "settings/personal-access-tokens"
+	default:
+		return host.BaseURL + "settings/tokens"
+	}
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+)
+
+// List runs the same traversal as a download with DryRun set, then prints
+// every file it would fetch and what the listing cost in API calls.
+func List(ctx context.Context, flags Flags, rawURL string) {
+	useAppAuth(flags)
+	githubURL, err := parseGitHubURL(rawURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing GitHub URL: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts, err := downloadOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.DryRun = true
+
+	// The visibility check and ref resolution cost calls too, before the
+	// listing starts counting its own.
+	var setupCalls atomic.Int64
+	ctx = repository.CountAPICalls(ctx, &setupCalls)
+
+	tokenManager := token.NewManagerForHost(githubURL.Host.Name)
+	if err := validateRuntimeConditions(ctx, flags, tokenManager, githubURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flags.Ref != "" {
+		githubURL.SetRef(flags.Ref)
+	}
+	if err := githubURL.ResolveRef(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flags.Format == FormatJSON {
+		result, err := githubURL.DownloadWithOptions(ctx, jsonOptions(opts, ""))
+		addAPICalls(result, &setupCalls)
+		printJSON(newSummaryEvent("summary", githubURL, result, err))
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !flags.Quiet {
+		printDownloadHeader("listing", githubURL, flags)
+	}
+
+	result, err := githubURL.DownloadWithOptions(ctx, opts)
+	addAPICalls(result, &setupCalls)
+	printListing(result)
+	if err != nil {
+		exitOnDownloadError(err, flags)
+	}
+}
+
+func addAPICalls(result *repository.Result, n *atomic.Int64) {
+	if result != nil {
+		result.APICalls += n.Load()
+	}
+}
+
+func printListing(result *repository.Result) {
+	if result == nil {
+		return
+	}
+
+	listed := result.Listed()
+	slices.SortFunc(listed, func(a, b repository.FileResult) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var total int64
+	for _, fr := range listed {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", fr.Type, fr.Size, shortSHA(fr.SHA), fr.Path)
+		total += fr.Size
+	}
+	w.Flush()
+
+	fmt.Printf("Files: %d, Total size: %s, API calls: %d\n", len(listed), repository.FormatSize(total), result.APICalls)
+
+	if failed := result.Failed(); len(failed) > 0 {
+		fmt.Println("Failed paths:")
+		for _, fr := range failed {
+			fmt.Printf("  ✗ %s: %v\n", displayPath(fr.Path), fr.Err)
+		}
+	}
+	if skipped := result.Skipped(); len(skipped) > 0 {
+		fmt.Println("Would skip:")
+		for _, fr := range skipped {
+			fmt.Printf("  - %s: %v\n", displayPath(fr.Path), fr.Err)
+		}
+	}
+}
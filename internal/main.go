@@ -2,37 +2,83 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"partial-git/internal/repository"
 	"partial-git/internal/token"
+	"strings"
 	"time"
+
+	"github.com/google/go-github/v57/github"
 )
 
 type Flags struct {
-	Set   string
-	Auth  bool
-	Check bool
-	Unset bool
+	Host      string
+	Set       string
+	Auth      bool
+	Check     bool
+	Unset     bool
+	Listing   string
+	Mode      string
+	Jobs      int
+	APIJobs   int
+	KeepGoing bool
+	Retries   int
+	WaitLimit bool
+
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+	StallTimeout   time.Duration
+
+	Output          string
+	StripComponents int
+	Flat            bool
+
+	Overwrite      bool
+	SkipExisting   bool
+	FailOnExisting bool
+	Update         bool
+
+	Submodules string
+	Ref        string
+	FromFile   string
+
+	Include     []string
+	Exclude     []string
+	MaxFileSize string
+	Patterns    string
+	Cone        bool
+
+	DryRun bool
+	Format string
+	Quiet  bool
+
+	Storage  string
+	ClientID string
+	Scopes   []string
+
+	AppID          int64
+	InstallationID int64
+	AppKey         string
 }
 
 func Run(ctx context.Context, flags Flags, args []string) {
-	tokenManager := token.NewManager()
+	host, err := repository.ParseHost(flags.Host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tokenManager := token.NewManagerForHost(host.Name)
+	app := useAppAuth(flags)
 
 	switch {
 	case flags.Set != "":
-		if err := tokenManager.SetToken(flags.Set); err != nil {
-			fmt.Fprintf(os.Stderr, "Error setting GitHub token: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Storage backend: %s\n", tokenManager.GetStorageInfo())
+		SetToken(flags, tokenManager, host)
 		return
 
 	case flags.Unset:
-		if err := tokenManager.DeleteToken(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error deleting GitHub token: %v\n", err)
-			os.Exit(1)
-		}
+		UnsetToken(flags, tokenManager, host)
 		return
 
 	case flags.Auth:
@@ -40,18 +86,47 @@ func Run(ctx context.Context, flags Flags, args []string) {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		showAuthInfo(ctx, tokenManager)
+		switch {
+		case app != nil && flags.Format == FormatJSON:
+			showAppInfoJSON(ctx, app, host)
+		case app != nil:
+			showAppInfo(ctx, app, host)
+		case flags.Format == FormatJSON:
+			showAuthInfoJSON(ctx, tokenManager, host)
+		default:
+			showAuthInfo(ctx, tokenManager, host)
+		}
 		return
 
 	case flags.Check:
+		// pgit --check <url> also tests access to that repository, with
+		// the token for its host.
+		var githubURL *repository.GitHubURL
+		if len(args) > 0 {
+			if githubURL, err = parseGitHubURL(args[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error parsing GitHub URL: %v\n", err)
+				os.Exit(1)
+			}
+			host = githubURL.Host
+			tokenManager = token.NewManagerForHost(host.Name)
+		}
 		if err := validateRuntimeConditions(ctx, flags, tokenManager, nil); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		checkTokenStatus(ctx, tokenManager)
+		if flags.Format == FormatJSON {
+			checkTokenStatusJSON(ctx, tokenManager, app, host, githubURL)
+		} else {
+			checkTokenStatus(ctx, tokenManager, app, host, githubURL)
+		}
 		return
 
 	default:
+		if len(args) > 1 || flags.FromFile != "" {
+			runBatch(ctx, flags, args)
+			return
+		}
+
 		if len(args) == 0 {
 			fmt.Fprintf(os.Stderr, "Error: GitHub URL is required\n")
 			fmt.Fprintf(os.Stderr, "Usage: pgit <github-url>\n")
@@ -62,6 +137,11 @@ func Run(ctx context.Context, flags Flags, args []string) {
 			os.Exit(1)
 		}
 
+		if flags.DryRun {
+			List(ctx, flags, args[0])
+			return
+		}
+
 		start := time.Now()
 		githubURL, err := parseGitHubURL(args[0])
 		if err != nil {
@@ -69,35 +149,210 @@ func Run(ctx context.Context, flags Flags, args []string) {
 			os.Exit(1)
 		}
 
+		opts, err := downloadOptions(flags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		tokenManager = token.NewManagerForHost(githubURL.Host.Name)
 		if err := validateRuntimeConditions(ctx, flags, tokenManager, githubURL); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Starting download of %s...\n", githubURL.String())
-		if githubURL.Path != "" {
-			fmt.Printf("Path: %s\n", githubURL.Path)
+		if flags.Ref != "" {
+			githubURL.SetRef(flags.Ref)
 		}
+		if err := githubURL.ResolveRef(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if flags.Format == FormatJSON {
+			result, err := githubURL.DownloadWithOptions(ctx, jsonOptions(opts, ""))
+			summary := newSummaryEvent("summary", githubURL, result, err)
+			summary.OutputDir = flags.Output
+			summary.DurationMS = time.Since(start).Milliseconds()
+			printJSON(summary)
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if !flags.Quiet {
+			printDownloadHeader("download", githubURL, flags)
+		}
+
+		result, err := githubURL.DownloadWithOptions(ctx, opts)
+		if !flags.Quiet || err != nil {
+			printDownloadSummary(result)
+		}
+		if err != nil {
+			exitOnDownloadError(err, flags)
+		}
+		if !flags.Quiet {
+			fmt.Println("Download Completed")
+			fmt.Println(time.Since(start))
+		}
+	}
+}
+
+func downloadOptions(flags Flags) (repository.Options, error) {
+	listMode, err := repository.ParseListMode(flags.Listing)
+	if err != nil {
+		return repository.Options{}, err
+	}
+
+	mode, err := repository.ParseDownloadMode(flags.Mode)
+	if err != nil {
+		return repository.Options{}, err
+	}
+
+	submodules, err := repository.ParseSubmodulePolicy(flags.Submodules)
+	if err != nil {
+		return repository.Options{}, err
+	}
+
+	filter := repository.Filter{Include: flags.Include, Exclude: flags.Exclude}
+	if err := filter.Validate(); err != nil {
+		return repository.Options{}, err
+	}
+	if flags.MaxFileSize != "" {
+		if filter.MaxSize, err = repository.ParseSize(flags.MaxFileSize); err != nil {
+			return repository.Options{}, fmt.Errorf("--max-file-size: %w", err)
+		}
+	}
+	if flags.Patterns != "" {
+		if filter.Patterns, err = repository.ReadPatterns(flags.Patterns, flags.Cone); err != nil {
+			return repository.Options{}, err
+		}
+	}
+
+	return repository.Options{
+		ListMode:  listMode,
+		Mode:      mode,
+		Jobs:      flags.Jobs,
+		ListJobs:  flags.APIJobs,
+		KeepGoing: flags.KeepGoing,
+		Quiet:     flags.Quiet,
+		Client: repository.ClientOptions{
+			Retries:          flags.Retries,
+			WaitForRateLimit: flags.WaitLimit,
+			RequestTimeout:   flags.RequestTimeout,
+			Quiet:            flags.Quiet,
+		},
+		Timeout:      flags.Timeout,
+		StallTimeout: flags.StallTimeout,
+
+		OutputDir:       flags.Output,
+		StripComponents: flags.StripComponents,
+		Flat:            flags.Flat,
+
+		Conflict:   conflictPolicy(flags),
+		Submodules: submodules,
+		Filter:     filter,
+	}, nil
+}
+
+func printDownloadHeader(action string, githubURL *repository.GitHubURL, flags Flags) {
+	fmt.Printf("Starting %s of %s...\n", action, githubURL.String())
+	if githubURL.Path != "" {
+		fmt.Printf("Path: %s\n", githubURL.Path)
+	}
+	switch githubURL.RefKind {
+	case repository.RefTag:
+		fmt.Printf("Tag: %s\n", githubURL.Branch)
+	case repository.RefCommit:
+		fmt.Printf("Commit: %s\n", githubURL.Branch)
+	default:
 		if githubURL.Branch != "" {
 			fmt.Printf("Branch: %s\n", githubURL.Branch)
 		}
+	}
+	if flags.Output != "" {
+		fmt.Printf("Output: %s\n", flags.Output)
+	}
+}
 
-		if err := githubURL.Download(ctx); err != nil {
-			switch err {
-			case repository.ErrTookTooLong:
-				fmt.Fprintf(os.Stderr, "Error: Download timed out after 60 seconds\n")
-			case context.Canceled:
-				fmt.Fprintf(os.Stderr, "Error: Download was cancelled\n")
-			default:
-				fmt.Fprintf(os.Stderr, "Error downloading repository: %v\n", err)
+func exitOnDownloadError(err error, flags Flags) {
+	switch {
+	case errors.Is(err, repository.ErrIncomplete):
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	case err == repository.ErrTookTooLong:
+		fmt.Fprintf(os.Stderr, "Error: Download timed out after %s\n", flags.Timeout)
+	case err == context.Canceled:
+		fmt.Fprintf(os.Stderr, "Error: Download was cancelled\n")
+	default:
+		fmt.Fprintf(os.Stderr, "Error downloading repository: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+func conflictPolicy(flags Flags) repository.ConflictPolicy {
+	switch {
+	case flags.SkipExisting:
+		return repository.ConflictSkip
+	case flags.FailOnExisting:
+		return repository.ConflictFail
+	case flags.Update:
+		return repository.ConflictUpdate
+	default:
+		return repository.ConflictOverwrite
+	}
+}
+
+func printDownloadSummary(result *repository.Result) {
+	if result == nil {
+		return
+	}
+
+	failed := result.Failed()
+	skipped := result.Skipped()
+	fmt.Printf("Downloaded: %d, Skipped: %d, Failed: %d\n", len(result.Downloaded()), len(skipped), len(failed))
+
+	if len(failed) > 0 {
+		fmt.Println("Failed paths:")
+		for _, fr := range failed {
+			fmt.Printf("  ✗ %s: %v\n", displayPath(fr.Path), fr.Err)
+		}
+	}
+	if submodules := submoduleResults(result); len(submodules) > 0 {
+		fmt.Println("Submodules:")
+		for _, fr := range submodules {
+			if fr.Err != nil {
+				fmt.Printf("  @ %s: %v\n", fr.Path, fr.Err)
+			} else {
+				fmt.Printf("  @ %s: fetched %s\n", fr.Path, shortSHA(fr.SHA))
 			}
-			os.Exit(1)
 		}
-		fmt.Println("Download Completed")
-		fmt.Println(time.Since(start))
+	}
+	if len(skipped) > 0 && len(failed) > 0 {
+		fmt.Println("Not downloaded:")
+		for _, fr := range skipped {
+			fmt.Printf("  - %s\n", displayPath(fr.Path))
+		}
 	}
 }
 
+func submoduleResults(result *repository.Result) []repository.FileResult {
+	var submodules []repository.FileResult
+	for _, fr := range result.Files() {
+		if fr.Type == "submodule" && fr.Status != repository.StatusFailed {
+			submodules = append(submodules, fr)
+		}
+	}
+	return submodules
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
 func parseGitHubURL(urlStr string) (*repository.GitHubURL, error) {
 	githubURL, err := repository.ParseGitHubURL(urlStr)
 	if err != nil {
@@ -107,7 +362,12 @@ func parseGitHubURL(urlStr string) (*repository.GitHubURL, error) {
 }
 
 func validateRuntimeConditions(ctx context.Context, flags Flags, tokenManager *token.Manager, githubURL *repository.GitHubURL) error {
-	if flags.Auth || flags.Check {
+	// --check reports where it looked when there is no token, and an app
+	// installation token is minted when first needed.
+	if flags.Check || flags.AppID != 0 {
+		return nil
+	}
+	if flags.Auth {
 		if !tokenManager.TokenExists() {
 			return fmt.Errorf("GitHub token not found. Use --set to configure it first")
 		}
@@ -117,8 +377,10 @@ func validateRuntimeConditions(ctx context.Context, flags Flags, tokenManager *t
 	if githubURL != nil {
 		isPrivate, err := isRepositoryPrivate(ctx, githubURL)
 		if err != nil {
-			fmt.Printf("Warning: Could not determine repository visibility: %v\n", err)
-			fmt.Println("Assuming repository might be private...")
+			if !flags.Quiet {
+				fmt.Fprintf(os.Stderr, "Warning: Could not determine repository visibility: %v\n", err)
+				fmt.Fprintln(os.Stderr, "Assuming repository might be private...")
+			}
 			if !tokenManager.TokenExists() {
 				return fmt.Errorf("GitHub token not found. Repository might be private. Use --set to configure a token")
 			}
@@ -136,7 +398,13 @@ func isRepositoryPrivate(ctx context.Context, githubURL *repository.GitHubURL) (
 	return githubURL.IsPrivate(ctx)
 }
 
-func showAuthInfo(ctx context.Context, tokenManager *token.Manager) {
+func hostClient(host repository.Host) *repository.GitHubClient {
+	opts := repository.DefaultClientOptions()
+	opts.Host = host
+	return repository.NewGitHubClientWithOptions(opts)
+}
+
+func showAuthInfo(ctx context.Context, tokenManager *token.Manager, host repository.Host) {
 	select {
 	case <-ctx.Done():
 		fmt.Println("Operation cancelled")
@@ -151,17 +419,18 @@ func showAuthInfo(ctx context.Context, tokenManager *token.Manager) {
 	}
 
 	fmt.Printf("GitHub token found (storage: %s)\n", tokenManager.GetStorageInfo())
-	fmt.Printf("Token prefix: %s***\n", storedToken[:8])
+	fmt.Printf("Token prefix: %s***\n", tokenPrefix(storedToken))
 
-	client := repository.NewGitHubClient()
+	client := hostClient(host)
 
-	user, err := client.GetAuthenticatedUser(ctx)
+	info, err := client.GetAuthInfo(ctx)
 	if err != nil {
 		fmt.Printf("Warning: Could not get user info: %v\n", err)
 		fmt.Println("Token appears to be valid but user info unavailable")
 		return
 	}
 
+	user := info.User
 	fmt.Printf("✓ Authenticated as: %s\n", user.GetLogin())
 	if user.GetName() != "" {
 		fmt.Printf("✓ Name: %s\n", user.GetName())
@@ -169,9 +438,35 @@ func showAuthInfo(ctx context.Context, tokenManager *token.Manager) {
 	if user.GetEmail() != "" {
 		fmt.Printf("✓ Email: %s\n", user.GetEmail())
 	}
+	if info.Scopes != nil {
+		fmt.Printf("✓ Scopes: %s\n", strings.Join(info.Scopes, ", "))
+	}
 }
 
-func checkTokenStatus(ctx context.Context, tokenManager *token.Manager) {
+func showAuthInfoJSON(ctx context.Context, tokenManager *token.Manager, host repository.Host) {
+	storedToken, err := tokenManager.GetToken()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error retrieving token: %v\n", err)
+		os.Exit(1)
+	}
+
+	out := authJSON{Host: host.Name, Token: newTokenJSON(true, tokenManager.GetStorageInfo(), storedToken)}
+	info, err := hostClient(host).GetAuthInfo(ctx)
+	if err != nil {
+		out.Error = err.Error()
+		printJSON(out)
+		os.Exit(1)
+	}
+
+	user := info.User
+	out.User = &userJSON{Login: user.GetLogin(), Name: user.GetName(), Email: user.GetEmail()}
+	out.Scopes = info.Scopes
+	printJSON(out)
+}
+
+// checkTokenStatus reports where the token comes from, what GitHub says
+// about it and, when a URL is given, whether it can read that repository.
+func checkTokenStatus(ctx context.Context, tokenManager *token.Manager, app *repository.AppAuth, host repository.Host, githubURL *repository.GitHubURL) {
 	select {
 	case <-ctx.Done():
 		fmt.Println("Operation cancelled")
@@ -179,23 +474,38 @@ func checkTokenStatus(ctx context.Context, tokenManager *token.Manager) {
 	default:
 	}
 
-	if !tokenManager.TokenExists() {
-		fmt.Println("No GitHub token configured")
-		fmt.Println("Use --set to configure a Personal Access Token")
+	var storedToken string
+	if app != nil {
+		fmt.Printf("✓ Authenticating as %s\n", appStorage(app))
+	} else if storedToken = checkStoredToken(tokenManager); storedToken == "" && githubURL == nil {
 		return
 	}
 
-	storedToken, err := tokenManager.GetToken()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error retrieving token: %v\n", err)
-		os.Exit(1)
+	client := hostClient(host)
+
+	// An installation token cannot read /user, which is where GitHub
+	// reports scopes and expiry.
+	var diagnosis *tokenDiagnosis
+	if storedToken != "" {
+		d := diagnoseToken(ctx, client, storedToken)
+		diagnosis = &d
+		printTokenDiagnosis(d)
 	}
 
-	fmt.Printf("✓ GitHub token found (storage: %s)\n", tokenManager.GetStorageInfo())
-	fmt.Printf("✓ Token format valid (prefix: %s***)\n", storedToken[:8])
+	if app != nil || storedToken != "" {
+		printRateLimit(ctx, client)
+	}
 
-	client := repository.NewGitHubClient()
+	if githubURL != nil {
+		access := client.CheckRepositoryAccess(ctx, githubURL.Owner, githubURL.Repository)
+		printRepoAccess(githubURL, access, diagnosis, app != nil)
+		if access.Repository == nil {
+			os.Exit(1)
+		}
+	}
+}
 
+func printRateLimit(ctx context.Context, client *repository.GitHubClient) {
 	rateLimits, err := client.GetRateLimit(ctx)
 	if err != nil {
 		fmt.Printf("Warning: Could not get rate limit info: %v\n", err)
@@ -214,3 +524,109 @@ func checkTokenStatus(ctx context.Context, tokenManager *token.Manager) {
 		fmt.Printf("⚠️  Warning: Low rate limit remaining (%d requests)\n", core.Remaining)
 	}
 }
+
+// checkStoredToken lists where a token was looked for and describes the one
+// found, returning it, or "" when there is none.
+func checkStoredToken(tokenManager *token.Manager) string {
+	fmt.Println("Token sources (first match wins):")
+	for _, source := range tokenManager.Sources() {
+		switch {
+		case source.Used:
+			fmt.Printf("  ✓ %s (used)\n", source.Name)
+		case source.Found:
+			fmt.Printf("  - %s (found, overridden)\n", source.Name)
+		default:
+			fmt.Printf("  - %s\n", source.Name)
+		}
+	}
+	fmt.Println()
+
+	if !tokenManager.TokenExists() {
+		fmt.Println("No GitHub token configured")
+		fmt.Println("Use --set to configure a Personal Access Token")
+		return ""
+	}
+
+	storedToken, err := tokenManager.GetToken()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error retrieving token: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ GitHub token found (storage: %s)\n", tokenManager.GetStorageInfo())
+	if err := token.ValidateToken(storedToken); err != nil {
+		fmt.Printf("⚠️  Token format not recognised: %v\n", err)
+	} else {
+		fmt.Printf("✓ Token format valid (prefix: %s***)\n", tokenPrefix(storedToken))
+	}
+	return storedToken
+}
+
+func checkTokenStatusJSON(ctx context.Context, tokenManager *token.Manager, app *repository.AppAuth, host repository.Host, githubURL *repository.GitHubURL) {
+	out := checkJSON{Host: host.Name}
+	client := hostClient(host)
+
+	var diagnosis *tokenDiagnosis
+	switch {
+	case app != nil:
+		out.Token = tokenJSON{Found: true, Storage: appStorage(app)}
+	case !tokenManager.TokenExists():
+		out.Sources = newSourcesJSON(tokenManager.Sources())
+	default:
+		out.Sources = newSourcesJSON(tokenManager.Sources())
+		storedToken, err := tokenManager.GetToken()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error retrieving token: %v\n", err)
+			os.Exit(1)
+		}
+		out.Token = newTokenJSON(true, tokenManager.GetStorageInfo(), storedToken)
+
+		d := diagnoseToken(ctx, client, storedToken)
+		diagnosis = &d
+		out.Token.Kind = d.kind
+		out.Token.Scopes = d.scopes
+		if !d.expires.IsZero() {
+			out.Token.Expires = &d.expires
+		}
+		if warning := expiryWarning(d.expires, time.Now()); warning != "" {
+			out.Warnings = append(out.Warnings, warning)
+		}
+		if d.err != nil {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("could not get token details: %v", d.err))
+		}
+	}
+
+	if out.Token.Found {
+		if rateLimits, err := client.GetRateLimit(ctx); err != nil {
+			out.Error = err.Error()
+		} else {
+			out.RateLimits = make(map[string]rateLimitJSON)
+			for name, rate := range map[string]*github.Rate{
+				"core":    rateLimits.GetCore(),
+				"search":  rateLimits.GetSearch(),
+				"graphql": rateLimits.GetGraphQL(),
+			} {
+				if rate != nil {
+					out.RateLimits[name] = newRateLimitJSON(rate)
+				}
+			}
+		}
+	}
+
+	if githubURL != nil {
+		access := client.CheckRepositoryAccess(ctx, githubURL.Owner, githubURL.Repository)
+		out.Repository = &repoCheckJSON{Repository: githubURL.Owner + "/" + githubURL.Repository, Status: access.Status}
+		if access.Repository != nil {
+			out.Repository.Accessible = true
+			out.Repository.Private = access.Repository.GetPrivate()
+			out.Repository.Permission = repoPermission(access)
+		} else {
+			out.Repository.Explanation = explainRepoAccess(access, diagnosis, app != nil)
+		}
+	}
+
+	printJSON(out)
+	if out.Repository != nil && !out.Repository.Accessible {
+		os.Exit(1)
+	}
+}
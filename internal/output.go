@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// The --format json schema. Downloads, ls and sync print newline-delimited
+// JSON: one "file" event per path as it finishes, then one "summary" (a batch
+// prints a "target" summary per target before the overall "summary").
+// --check and --auth print a single object. Fields are only ever added.
+
+type fileEvent struct {
+	Event     string `json:"event"`
+	Target    string `json:"target,omitempty"`
+	Path      string `json:"path"`
+	Type      string `json:"type,omitempty"`
+	Status    string `json:"status"`
+	SHA       string `json:"sha,omitempty"`
+	Size      int64  `json:"size"`
+	LocalPath string `json:"local_path,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type summaryEvent struct {
+	Event      string `json:"event"`
+	Target     string `json:"target,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	OutputDir  string `json:"output_dir,omitempty"`
+
+	Downloaded int   `json:"downloaded"`
+	Skipped    int   `json:"skipped"`
+	Failed     int   `json:"failed"`
+	Listed     int   `json:"listed,omitempty"`
+	Bytes      int64 `json:"bytes"`
+	APICalls   int64 `json:"api_calls"`
+
+	// Batch summaries only.
+	Targets       int `json:"targets,omitempty"`
+	FailedTargets int `json:"failed_targets,omitempty"`
+
+	// Sync summaries only.
+	Added    []string `json:"added,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Kept     []string `json:"kept,omitempty"`
+	Manifest string   `json:"manifest,omitempty"`
+
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type tokenJSON struct {
+	Found   bool   `json:"found"`
+	Storage string `json:"storage,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	// Kind, Scopes and Expires are only reported by --check. Scopes is null
+	// for fine-grained tokens.
+	Kind    string     `json:"kind,omitempty"`
+	Scopes  []string   `json:"scopes,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+type rateLimitJSON struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// sourceJSON is one place --check looked for a token, in lookup order.
+type sourceJSON struct {
+	Name  string `json:"name"`
+	Found bool   `json:"found"`
+	Used  bool   `json:"used"`
+}
+
+// repoCheckJSON is the result of pgit --check <url>.
+type repoCheckJSON struct {
+	Repository  string `json:"repository"`
+	Accessible  bool   `json:"accessible"`
+	Status      int    `json:"status,omitempty"`
+	Private     bool   `json:"private,omitempty"`
+	Permission  string `json:"permission,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+type checkJSON struct {
+	Host       string                   `json:"host"`
+	Token      tokenJSON                `json:"token"`
+	Sources    []sourceJSON             `json:"sources"`
+	RateLimits map[string]rateLimitJSON `json:"rate_limits,omitempty"`
+	Repository *repoCheckJSON           `json:"repository,omitempty"`
+	Warnings   []string                 `json:"warnings,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// accountJSON is what --set, --unset, login and logout print. Token is the
+// token pgit reads for the host once the change is made.
+type accountJSON struct {
+	Host   string    `json:"host"`
+	Action string    `json:"action"`
+	Token  tokenJSON `json:"token"`
+	User   *userJSON `json:"user,omitempty"`
+	// Deleted reports, for unset and logout, whether pgit held a token.
+	Deleted   bool     `json:"deleted,omitempty"`
+	RevokeURL string   `json:"revoke_url,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type userJSON struct {
+	Login string `json:"login"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// appJSON replaces the user when authenticating as a GitHub App.
+type appJSON struct {
+	ID                  int64    `json:"id"`
+	Slug                string   `json:"slug"`
+	Name                string   `json:"name"`
+	InstallationID      int64    `json:"installation_id"`
+	Account             string   `json:"account"`
+	RepositorySelection string   `json:"repository_selection"`
+	Permissions         []string `json:"permissions"`
+}
+
+type authJSON struct {
+	Host  string    `json:"host"`
+	Token tokenJSON `json:"token"`
+	User  *userJSON `json:"user,omitempty"`
+	App   *appJSON  `json:"app,omitempty"`
+	// Scopes is null for tokens without OAuth scopes, such as fine-grained
+	// tokens, and [] for a classic token with none.
+	Scopes []string `json:"scopes"`
+	Error  string   `json:"error,omitempty"`
+}
+
+var stdoutMu sync.Mutex
+
+// printJSON writes v as one line of JSON. Download workers call it
+// concurrently, so lines are never interleaved.
+func printJSON(v any) {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+	}
+}
+
+// jsonOptions switches a download to JSON reporting: the downloader's own
+// progress lines are silenced and every outcome becomes a file event.
+func jsonOptions(opts repository.Options, target string) repository.Options {
+	opts.Quiet = true
+	opts.Client.Quiet = true
+	opts.OnResult = func(fr repository.FileResult) {
+		printJSON(newFileEvent(target, fr))
+	}
+	return opts
+}
+
+func newFileEvent(target string, fr repository.FileResult) fileEvent {
+	event := fileEvent{
+		Event:     "file",
+		Target:    target,
+		Path:      fr.Path,
+		Type:      fr.Type,
+		Status:    string(fr.Status),
+		SHA:       fr.SHA,
+		Size:      fr.Size,
+		LocalPath: fr.LocalPath,
+	}
+	if fr.Err != nil {
+		event.Error = fr.Err.Error()
+	}
+	return event
+}
+
+func newSummaryEvent(event string, githubURL *repository.GitHubURL, result *repository.Result, err error) summaryEvent {
+	summary := summaryEvent{
+		Event:      event,
+		Repository: githubURL.String(),
+		Path:       githubURL.Path,
+		Ref:        githubURL.Branch,
+	}
+	if result != nil {
+		summary.Commit = result.Commit
+		summary.APICalls = result.APICalls
+		summary.Downloaded = len(result.Downloaded())
+		summary.Skipped = len(result.Skipped())
+		summary.Failed = len(result.Failed())
+		summary.Listed = len(result.Listed())
+		for _, fr := range result.Files() {
+			if fr.Status == repository.StatusDownloaded || fr.Status == repository.StatusListed {
+				summary.Bytes += fr.Size
+			}
+		}
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return summary
+}
+
+func newTokenJSON(found bool, storage, storedToken string) tokenJSON {
+	return tokenJSON{Found: found, Storage: storage, Prefix: tokenPrefix(storedToken)}
+}
+
+// tokenPrefix is as much of a token as is safe to show.
+func tokenPrefix(storedToken string) string {
+	if len(storedToken) < 8 {
+		return ""
+	}
+	return storedToken[:8]
+}
+
+func newSourcesJSON(sources []token.SourceStatus) []sourceJSON {
+	out := make([]sourceJSON, 0, len(sources))
+	for _, s := range sources {
+		out = append(out, sourceJSON{Name: s.Name, Found: s.Found, Used: s.Used})
+	}
+	return out
+}
+
+func newRateLimitJSON(rate *github.Rate) rateLimitJSON {
+	return rateLimitJSON{
+		Limit:     rate.Limit,
+		Remaining: rate.Remaining,
+		Reset:     rate.Reset.Time,
+	}
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/oauth2"
+)
+
+// AppAuth authenticates as an installation of a GitHub App rather than as a
+// user. A JWT signed with the app's private key is exchanged for an
+// installation token, which is reused until it is about to expire.
+type AppAuth struct {
+	AppID          int64
+	InstallationID int64
+
+	key     *rsa.PrivateKey
+	sources sync.Map // host name -> oauth2.TokenSource
+}
+
+// NewAppAuth reads the app's PEM-encoded private key, as downloaded from the
+// app's settings page.
+func NewAppAuth(appID, installationID int64, keyFile string) (*AppAuth, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM-encoded private key", keyFile)
+	}
+
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed any
+		if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			var ok bool
+			if key, ok = parsed.(*rsa.PrivateKey); !ok {
+				err = fmt.Errorf("not an RSA key")
+			}
+		}
+	default:
+		err = fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid app private key %s: %w", keyFile, err)
+	}
+
+	return &AppAuth{AppID: appID, InstallationID: installationID, key: key}, nil
+}
+
+// appAuth, when set, authenticates every client in place of a stored token.
+var appAuth atomic.Pointer[AppAuth]
+
+// UseAppAuth makes every GitHubClient created from now on authenticate as
+// the app installation.
+func UseAppAuth(app *AppAuth) {
+	appAuth.Store(app)
+}
+
+// tokenSource returns the installation token source for host. Clients for
+// the same host share it, so a run exchanges a JWT once per hour at most.
+func (a *AppAuth) tokenSource(host Host) oauth2.TokenSource {
+	if source, ok := a.sources.Load(host.Name); ok {
+		return source.(oauth2.TokenSource)
+	}
+	source, _ := a.sources.LoadOrStore(host.Name, oauth2.ReuseTokenSource(nil, &installationTokenSource{app: a, host: host}))
+	return source.(oauth2.TokenSource)
+}
+
+// jwt returns a token identifying the app itself, valid for a few minutes.
+func (a *AppAuth) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		// Backdated to allow for clock drift; GitHub rejects anything
+		// lasting over ten minutes.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.AppID,
+	})
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign app JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+// appClient is an API client authenticated with the app's JWT, for the few
+// endpoints that accept it.
+func (a *AppAuth) appClient(host Host) (*github.Client, error) {
+	jwt, err := a.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &oauth2.Transport{
+		Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt}),
+		Base:   newTransport(DefaultRequestTimeout),
+	}
+	client := github.NewClient(&http.Client{Transport: transport})
+	if host.IsEnterprise() {
+		client, _ = client.WithEnterpriseURLs(host.BaseURL, host.BaseURL)
+	}
+	return client, nil
+}
+
+type installationTokenSource struct {
+	app  *AppAuth
+	host Host
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	client, err := s.app.appClient(s.host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	token, _, err := client.Apps.CreateInstallationToken(ctx, s.app.InstallationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a token for installation %d of app %d: %w", s.app.InstallationID, s.app.AppID, err)
+	}
+
+	return &oauth2.Token{AccessToken: token.GetToken(), Expiry: token.GetExpiresAt().Time}, nil
+}
+
+// AppInfo describes the app and the installation a client acts as.
+type AppInfo struct {
+	App          *github.App
+	Installation *github.Installation
+}
+
+// Describe fetches the app and installation, which --auth shows in place of
+// a user: an installation token cannot read /user.
+func (a *AppAuth) Describe(ctx context.Context, host Host) (*AppInfo, error) {
+	if !host.IsEnterprise() {
+		host = DotCom
+	}
+	client, err := a.appClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	app, _, err := client.Apps.Get(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app %d: %w", a.AppID, err)
+	}
+	installation, _, err := client.Apps.GetInstallation(ctx, a.InstallationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation %d: %w", a.InstallationID, err)
+	}
+	return &AppInfo{App: app, Installation: installation}, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAppID          = 1234
+	testInstallationID = 5678
+)
+
+// fakeAppServer mints installation tokens for one app, checking each JWT it
+// is given, and records the token every other request carries.
+type fakeAppServer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PublicKey
+	// lifetimes are the expiry of each token minted, in order; the last
+	// one repeats.
+	lifetimes []time.Duration
+
+	mu     sync.Mutex
+	minted int
+	used   []string
+}
+
+func (s *fakeAppServer) serve(w http.ResponseWriter, r *http.Request) {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if r.URL.Path == fmt.Sprintf("/api/v3/app/installations/%d/access_tokens", testInstallationID) {
+		if err := s.checkJWT(auth); err != nil {
+			s.t.Errorf("installation token request: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		s.minted++
+		n := s.minted
+		lifetime := s.lifetimes[min(n, len(s.lifetimes))-1]
+		s.mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"token":      fmt.Sprintf("ghs_token%d", n),
+			"expires_at": time.Now().Add(lifetime).UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	s.mu.Lock()
+	s.used = append(s.used, auth)
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{"name": testRepo})
+}
+
+// checkJWT verifies the signature and the claims GitHub insists on.
+func (s *fakeAppServer) checkJWT(jwt string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT %q", jwt)
+	}
+
+	var header struct{ Alg string }
+	var claims struct{ Iat, Exp, Iss int64 }
+	for i, v := range []any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return err
+		}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(s.key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("bad signature: %w", err)
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case header.Alg != "RS256":
+		return fmt.Errorf("alg = %s, want RS256", header.Alg)
+	case claims.Iss != testAppID:
+		return fmt.Errorf("iss = %d, want %d", claims.Iss, testAppID)
+	case claims.Iat >= now:
+		return fmt.Errorf("iat = %d is not backdated from %d", claims.Iat, now)
+	case claims.Exp <= now:
+		return fmt.Errorf("exp = %d has passed", claims.Exp)
+	case claims.Exp-claims.Iat > int64((10 * time.Minute).Seconds()):
+		return fmt.Errorf("JWT lasts %ds, over ten minutes", claims.Exp-claims.Iat)
+	}
+	return nil
+}
+
+func newAppAuth(t *testing.T) (*AppAuth, *rsa.PublicKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := NewAppAuth(testAppID, testInstallationID, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, &key.PublicKey
+}
+
+func TestAppAuthInstallationToken(t *testing.T) {
+	app, key := newAppAuth(t)
+	// The first token is about to expire, so the second request has to
+	// fetch a new one; the second lasts the rest of the test.
+	s := &fakeAppServer{t: t, key: key, lifetimes: []time.Duration{5 * time.Second, time.Hour}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	t.Setenv(EnterpriseHostsEnv, s.URL)
+	host, err := ParseHost(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	UseAppAuth(app)
+	t.Cleanup(func() { UseAppAuth(nil) })
+	client := NewGitHubClientWithOptions(ClientOptions{Host: host})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetRepository(context.Background(), testOwner, testRepo); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+
+	want := []string{"ghs_token1", "ghs_token2", "ghs_token2"}
+	if !slices.Equal(s.used, want) {
+		t.Errorf("requests carried %v, want %v", s.used, want)
+	}
+	if s.minted != 2 {
+		t.Errorf("%d installation tokens minted, want 2", s.minted)
+	}
+}
@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// archiveThreshold is the file count at which ModeAuto stops fetching files
+// one by one and streams the repository tarball instead.
+const archiveThreshold = 200
+
+// archiveMinShare is the share of the repository's size a subdirectory must
+// make up for ModeAuto to stream the tarball for it. The tarball holds the
+// whole repository, so a few hundred small files deep inside a large one are
+// cheaper to fetch one by one.
+const archiveMinShare = 0.1
+
+// DownloadMode selects how file contents are fetched once they are listed.
+type DownloadMode int
+
+const (
+	// ModeAuto fetches files individually unless the listing is large enough
+	// that streaming the archive is cheaper.
+	ModeAuto DownloadMode = iota
+	// ModeFiles fetches every file with its own raw download request.
+	ModeFiles
+	// ModeArchive streams the tarball for the ref and extracts the subtree.
+	ModeArchive
+)
+
+func ParseDownloadMode(s string) (DownloadMode, error) {
+	switch s {
+	case "", "auto":
+		return ModeAuto, nil
+	case "files":
+		return ModeFiles, nil
+	case "archive":
+		return ModeArchive, nil
+	default:
+		return ModeAuto, fmt.Errorf("unknown download mode %q (expected auto, files or archive)", s)
+	}
+}
+
+func (m DownloadMode) String() string {
+	switch m {
+	case ModeFiles:
+		return "files"
+	case ModeArchive:
+		return "archive"
+	default:
+		return "auto"
+	}
+}
+
+// useArchive decides whether to stream the tarball for files, the ones that
+// will actually be written.
+func (d *Downloader) useArchive(ctx context.Context, files []remoteFile) bool {
+	switch d.mode {
+	case ModeArchive:
+		return true
+	case ModeFiles:
+		return false
+	default:
+		return len(files) >= archiveThreshold && d.largeShare(ctx, files)
+	}
+}
+
+// largeShare reports whether files make up enough of the repository to be
+// worth its tarball. GitHub reports the size of the repository with its
+// history, compressed, so the comparison is rough and leans towards fetching
+// files one by one. When the size is unknown the file count alone decides.
+func (d *Downloader) largeShare(ctx context.Context, files []remoteFile) bool {
+	if d.basePath == "" {
+		return true
+	}
+
+	if err := d.budget.list.acquire(ctx); err != nil {
+		return true
+	}
+	repo, err := d.client.GetRepository(ctx, d.owner, d.repo)
+	d.budget.list.release()
+	if err != nil || repo.GetSize() == 0 {
+		return true
+	}
+
+	var size int64
+	for _, file := range files {
+		size += int64(file.Size)
+	}
+	return float64(size) >= archiveMinShare*float64(repo.GetSize())*1024
+}
+
+func (d *Downloader) streamArchive(ctx context.Context) {
+	defer d.pending.Done()
+
+	if err := d.downloadArchive(ctx); err != nil {
+		d.record(ctx, remoteFile{Path: d.basePath}, "", err)
+	}
+}
+
+// errArchiveMismatch marks an archive entry whose content is not the blob in
+// the listing, as happens to files marked export-subst in .gitattributes.
+var errArchiveMismatch = errors.New("archive content differs from the listed blob")
+
+// downloadArchive streams the gzipped tarball for the ref and writes every
+// entry under basePath as it goes past, so nothing but the selected files ever
+// touches the disk. Listed files the archive leaves out (export-ignore) or
+// rewrites (export-subst) are fetched individually afterwards.
+func (d *Downloader) downloadArchive(ctx context.Context) error {
+	if err := d.budget.fetch.acquire(ctx); err != nil {
+		return err
+	}
+	defer d.budget.fetch.release()
+
+	link, err := d.client.GetArchiveLink(ctx, d.owner, d.repo, d.commitSHA)
+	if err != nil {
+		return fmt.Errorf("failed to get archive link for %s/%s: %w", d.owner, d.repo, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", link.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create archive request: %w", err)
+	}
+
+	resp, err := d.get(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download archive: received HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	found := false
+	seen := make(map[string]bool, len(d.listed))
+	var refetch []remoteFile
+	tr := tar.NewReader(gz)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeSymlink {
+			continue
+		}
+
+		repoPath, ok := stripArchiveRoot(header.Name)
+		if !ok {
+			continue
+		}
+
+		if !d.inBasePath(repoPath) {
+			// git archive writes each directory contiguously, so once the
+			// subtree has been passed there is nothing left to extract.
+			if found {
+				break
+			}
+			continue
+		}
+		found = true
+
+		file, ok := d.listed[repoPath]
+		switch {
+		case ok:
+			seen[repoPath] = true
+		case d.listed != nil:
+			// Left out of the listing by the filter.
+			continue
+		default:
+			file = archiveFile(repoPath, header)
+			if !d.wanted(ctx, file) {
+				continue
+			}
+			d.progress.expect(file)
+		}
+
+		localPath, err := d.saveArchiveEntry(file, header, tr)
+		if errors.Is(err, errArchiveMismatch) {
+			refetch = append(refetch, file)
+			continue
+		}
+		d.record(ctx, file, localPath, err)
+		d.progress.finish()
+	}
+
+	if d.listed == nil {
+		if !found {
+			return fmt.Errorf("path '%s' not found in %s/%s archive", d.basePath, d.owner, d.repo)
+		}
+		return nil
+	}
+
+	for path, file := range d.listed {
+		if !seen[path] && file.Type != "submodule" {
+			refetch = append(refetch, file)
+		}
+	}
+	for _, file := range refetch {
+		d.logf("Not in archive as listed, fetching: %s\n", file.Path)
+		if file.Type == "file" {
+			file.DownloadURL = d.rawURL(file.Path)
+		}
+		// Progress already expects every listed file.
+		d.pending.Add(1)
+		d.files.push(file)
+	}
+
+	return nil
+}
+
+// archiveFile describes a tar entry that was not in a listing, as happens
+// when the archive is streamed without one. The size comes from the header so
+// that the size limit applies before anything is written.
+func archiveFile(repoPath string, header *tar.Header) remoteFile {
+	file := remoteFile{Path: repoPath, Type: "file", Size: int(header.Size)}
+	switch {
+	case header.Typeflag == tar.TypeSymlink:
+		file.Type, file.Mode = "symlink", symlinkMode
+	case header.Mode&0111 != 0:
+		file.Mode = executableMode
+	}
+	return file
+}
+
+// saveArchiveEntry writes one tar entry. file comes from the tree listing when
+// there was one, which supplies the blob SHA for --update.
+func (d *Downloader) saveArchiveEntry(file remoteFile, header *tar.Header, r io.Reader) (string, error) {
+	repoPath := file.Path
+	localPath, err := d.getExactPath(d.basePath, repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine local path for %s: %w", repoPath, err)
+	}
+
+	if err := d.checkExisting(localPath, file); err != nil {
+		return localPath, err
+	}
+
+	if header.Typeflag == tar.TypeSymlink {
+		d.logf("Linking: %s -> %s\n", repoPath, header.Linkname)
+		return localPath, d.writeSymlink(localPath, header.Linkname)
+	}
+
+	d.logf("Extracting: %s\n", repoPath)
+
+	if file.SHA == "" {
+		return localPath, d.writeFile(localPath, d.progress.reader(r), fileMode(file.Mode))
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", header.Size)
+	if err := d.writeFile(localPath, io.TeeReader(d.progress.reader(r), h), fileMode(file.Mode)); err != nil {
+		return localPath, err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != file.SHA {
+		// Removed so that the conflict policy, which has already let this
+		// file through, does not trip over the bad copy on the refetch.
+		if err := os.Remove(localPath); err != nil {
+			return localPath, fmt.Errorf("failed to remove %s: %w", localPath, err)
+		}
+		return localPath, errArchiveMismatch
+	}
+	return localPath, nil
+}
+
+// stripArchiveRoot removes the "<owner>-<repo>-<sha>/" directory GitHub wraps
+// every archive in.
+func stripArchiveRoot(name string) (string, bool) {
+	_, rest, ok := strings.Cut(name, "/")
+	if !ok || rest == "" {
+		return "", false
+	}
+	return rest, true
+}
+
+func (d *Downloader) inBasePath(repoPath string) bool {
+	return d.basePath == "" || repoPath == d.basePath || strings.HasPrefix(repoPath, d.basePath+"/")
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadArchiveFetchesEntriesMissingOrRewritten(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{
+		"docs/a.txt":      "alpha\n",
+		"docs/ignored.md": "left out of the archive\n",
+		"docs/version.go": "const commit = \"$Format:%H$\"\n",
+	})
+	subst := "const commit = \"" + testCommit + "\"\n"
+	f.archive["docs/ignored.md"] = nil
+	f.archive["docs/version.go"] = &subst
+
+	result, dir, err := f.download(t, "", Options{Mode: ModeArchive})
+	if err != nil {
+		t.Fatalf("Download: %v (failed: %v)", err, result.Failed())
+	}
+
+	for path, want := range f.files {
+		if got := readFile(t, dir, filepath.Join(testRepo, path)); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	if got := len(result.Downloaded()); got != len(f.files) {
+		t.Errorf("downloaded %d files, want %d", got, len(f.files))
+	}
+}
+
+func TestDownloadArchiveWithoutListing(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{
+		"docs/a.txt": "alpha\n",
+		"src/b.go":   "package b\n",
+	})
+
+	_, dir, err := f.download(t, "docs", Options{Mode: ModeArchive, ListMode: ListContents})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	if got := readFile(t, dir, "docs/a.txt"); got != "alpha\n" {
+		t.Errorf("docs/a.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "src")); !os.IsNotExist(err) {
+		t.Errorf("src was extracted outside the requested path")
+	}
+}
+
+func TestDownloadArchiveWithoutListingAppliesSizeLimit(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{
+		"docs/small.txt": "tiny\n",
+		"docs/large.bin": "far more than sixteen bytes\n",
+	})
+
+	result, dir, err := f.download(t, "docs", Options{Mode: ModeArchive, ListMode: ListContents, Filter: Filter{MaxSize: 16}})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	if got := readFile(t, dir, "docs/small.txt"); got != "tiny\n" {
+		t.Errorf("docs/small.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs", "large.bin")); !os.IsNotExist(err) {
+		t.Errorf("docs/large.bin was written despite the size limit")
+	}
+	if skipped := result.Skipped(); len(skipped) != 1 || skipped[0].Path != "docs/large.bin" {
+		t.Errorf("skipped = %v, want docs/large.bin", skipped)
+	}
+}
+
+func TestAutoModeWeighsRepositorySize(t *testing.T) {
+	files := manyFiles([]string{"big"}, archiveThreshold)
+	var subtree int
+	for _, content := range files {
+		subtree += len(content)
+	}
+
+	tests := []struct {
+		name         string
+		basePath     string
+		repoSizeKB   int
+		wantArchives int64
+	}{
+		{"whole repository", "", 1 << 20, 1},
+		{"large share", "big", 1, 1},
+		{"small share of a large repository", "big", 100 * subtree / 1024, 0},
+		{"size unknown", "big", 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGitHub(t, files)
+			f.repoSizeKB = tt.repoSizeKB
+
+			result, _, err := f.download(t, tt.basePath, Options{})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if got := f.archives.Load(); got != tt.wantArchives {
+				t.Errorf("%d archive downloads, want %d", got, tt.wantArchives)
+			}
+			if got := len(result.Downloaded()); got != len(files) {
+				t.Errorf("downloaded %d files, want %d", got, len(files))
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Target is one entry of a batch: a URL and the directory its files go to.
+type Target struct {
+	URL *GitHubURL
+	// OutputDir overrides Options.OutputDir for this target when set. No
+	// two targets may name the same directory.
+	OutputDir string
+	// OnResult overrides Options.OnResult for this target when set.
+	OnResult func(FileResult)
+}
+
+type TargetResult struct {
+	Target Target
+	Result *Result
+	Err    error
+}
+
+// ReadTargets parses a spec file with one target per line:
+//
+//	https://github.com/owner/repo/tree/main/config -> vendor/config
+//	owner/other@v1.2.0:proto
+//
+// The destination after "->" is optional; a relative one is placed under
+// Options.OutputDir. Blank lines and lines starting with # are ignored.
+func ReadTargets(path string) ([]Target, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spec file: %w", err)
+	}
+	defer file.Close()
+
+	var targets []Target
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rawURL, dest, hasDest := strings.Cut(line, "->")
+		dest = strings.TrimSpace(dest)
+		if hasDest && (dest == "" || strings.Contains(dest, "->")) {
+			return nil, fmt.Errorf("%s:%d: expected one destination after \"->\"", path, lineNo)
+		}
+		githubURL, err := ParseGitHubURL(strings.TrimSpace(rawURL))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		targets = append(targets, Target{URL: githubURL, OutputDir: dest})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+
+	return targets, nil
+}
+
+// DownloadBatch downloads every target concurrently. They share one API
+// client per host and one concurrency budget, so a batch of many small
+// targets makes no more simultaneous requests than a single download would.
+// Without KeepGoing, the first target to fail cancels the rest.
+func DownloadBatch(ctx context.Context, targets []Target, opts Options) ([]TargetResult, error) {
+	if err := CheckDestinations(targets, opts.OutputDir); err != nil {
+		return nil, err
+	}
+
+	var cancel context.CancelFunc
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	jobs, listJobs := opts.Jobs, opts.ListJobs
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
+	if listJobs <= 0 {
+		listJobs = DefaultListJobs
+	}
+	opts.budget = newBudget(jobs, listJobs)
+	if !opts.Quiet {
+		opts.progress = newProgress()
+		opts.progress.start()
+		defer opts.progress.stop()
+	}
+	opts.Timeout = 0 // ctx carries the deadline for the whole batch
+
+	clients := make(map[string]*GitHubClient)
+	for _, target := range targets {
+		if _, ok := clients[target.URL.Host.Name]; !ok {
+			clientOpts := opts.Client
+			clientOpts.Host = target.URL.Host
+			clients[target.URL.Host.Name] = NewGitHubClientWithOptions(clientOpts)
+		}
+	}
+
+	results := make([]TargetResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			targetOpts := opts
+			if target.OutputDir != "" {
+				targetOpts.OutputDir = joinOutputDir(opts.OutputDir, target.OutputDir)
+			}
+			if target.OnResult != nil {
+				targetOpts.OnResult = target.OnResult
+			}
+
+			result, err := target.URL.downloadTarget(ctx, clients[target.URL.Host.Name], targetOpts)
+			results[i] = TargetResult{Target: target, Result: result, Err: err}
+			if err != nil && !opts.KeepGoing {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed int
+	for _, tr := range results {
+		if tr.Err != nil && !errors.Is(tr.Err, context.Canceled) {
+			failed++
+		}
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return results, ErrTookTooLong
+	case failed == 0 && ctx.Err() != nil:
+		return results, ctx.Err()
+	case failed > 0:
+		return results, fmt.Errorf("%w: %d of %d target(s) failed", ErrIncomplete, failed, len(targets))
+	}
+
+	return results, nil
+}
+
+func (g *GitHubURL) downloadTarget(ctx context.Context, client *GitHubClient, opts Options) (*Result, error) {
+	if err := g.resolveRef(ctx, client); err != nil {
+		return nil, err
+	}
+	return g.downloadWith(ctx, client, opts)
+}
+
+// CheckDestinations refuses targets that name the same output directory.
+// Each target's downloader keeps its own record of the paths it has written,
+// so two of them could overwrite each other's files unnoticed. DownloadBatch
+// checks this itself; calling it first lets a caller fail before any work.
+func CheckDestinations(targets []Target, outputDir string) error {
+	owners := make(map[string]string)
+	for _, target := range targets {
+		if target.OutputDir == "" {
+			continue
+		}
+
+		dest, err := filepath.Abs(joinOutputDir(outputDir, target.OutputDir))
+		if err != nil {
+			return err
+		}
+		if owner, ok := owners[dest]; ok {
+			return fmt.Errorf("%s and %s both download to %s", owner, target.URL.String(), target.OutputDir)
+		}
+		owners[dest] = target.URL.String()
+	}
+	return nil
+}
+
+// joinOutputDir places a relative destination under the --output directory.
+func joinOutputDir(base, dest string) string {
+	if base == "" || filepath.IsAbs(dest) {
+		return dest
+	}
+	return filepath.Join(base, dest)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSpec(t *testing.T, spec string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadTargets(t *testing.T) {
+	path := writeSpec(t, `# vendored configuration
+
+https://github.com/owner/repo/tree/main/config -> vendor/config
+   owner/other@v1.2.0:proto
+	# indented comment
+owner/abs -> /srv/abs
+owner/spaced->  spaced dir  
+`)
+
+	targets, err := ReadTargets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ repo, dest string }{
+		{"owner/repo", "vendor/config"},
+		{"owner/other", ""},
+		{"owner/abs", "/srv/abs"},
+		{"owner/spaced", "spaced dir"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("got %d targets, want %d", len(targets), len(want))
+	}
+	for i, w := range want {
+		if got := targets[i].URL.Owner + "/" + targets[i].URL.Repository; got != w.repo {
+			t.Errorf("target %d repository = %s, want %s", i, got, w.repo)
+		}
+		if got := targets[i].OutputDir; got != w.dest {
+			t.Errorf("target %d destination = %q, want %q", i, got, w.dest)
+		}
+	}
+}
+
+func TestReadTargetsRejectsMalformedLines(t *testing.T) {
+	for _, line := range []string{
+		"not a url at all",
+		"-> vendor/config",
+		"owner/repo ->",
+		"owner/repo -> a -> b",
+	} {
+		path := writeSpec(t, "owner/fine\n"+line+"\n")
+		_, err := ReadTargets(path)
+		if err == nil || !strings.Contains(err.Error(), path+":2:") {
+			t.Errorf("%q: err = %v, want one naming line 2", line, err)
+		}
+	}
+}
+
+func TestJoinOutputDir(t *testing.T) {
+	abs := filepath.Join(t.TempDir(), "abs")
+	tests := []struct {
+		base, dest, want string
+	}{
+		{"", "vendor/config", "vendor/config"},
+		{"out", "vendor/config", filepath.Join("out", "vendor", "config")},
+		{"out", abs, abs},
+		{"", abs, abs},
+	}
+
+	for _, tt := range tests {
+		if got := joinOutputDir(tt.base, tt.dest); got != tt.want {
+			t.Errorf("joinOutputDir(%q, %q) = %q, want %q", tt.base, tt.dest, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDestinations(t *testing.T) {
+	target := func(url, dest string) Target {
+		g, err := ParseGitHubURL(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Target{URL: g, OutputDir: dest}
+	}
+	out := t.TempDir()
+
+	tests := []struct {
+		name    string
+		targets []Target
+		wantErr bool
+	}{
+		{"distinct", []Target{target("owner/a", "a"), target("owner/b", "b")}, false},
+		{"no destinations", []Target{target("owner/a", ""), target("owner/b", "")}, false},
+		{"same destination", []Target{target("owner/a", "vendor"), target("owner/b", "vendor")}, true},
+		{"same after cleaning", []Target{target("owner/a", "vendor"), target("owner/b", "./vendor/")}, true},
+		{"relative and absolute", []Target{target("owner/a", "vendor"), target("owner/b", filepath.Join(out, "vendor"))}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDestinations(tt.targets, out)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckDestinations = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadBatchRejectsSharedDestination(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{"a/x.txt": "x\n", "b/x.txt": "y\n"})
+	var targets []Target
+	for _, dir := range []string{"a", "b"} {
+		g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo + "/tree/main/" + dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, Target{URL: g, OutputDir: "same"})
+	}
+
+	out := t.TempDir()
+	_, err := DownloadBatch(context.Background(), targets, Options{Quiet: true, OutputDir: out, Flat: true})
+	if err == nil || !strings.Contains(err.Error(), "both download to same") {
+		t.Fatalf("DownloadBatch = %v, want the shared destination refused", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "same")); !os.IsNotExist(err) {
+		t.Errorf("files were written before the check: %v", err)
+	}
+}
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
-	"os"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"partial-git/internal/token"
 
@@ -10,44 +14,201 @@ import (
 	"golang.org/x/oauth2"
 )
 
+type ClientOptions struct {
+	// Retries is how many times a transient failure is retried.
+	Retries int
+	// WaitForRateLimit sleeps until an exhausted rate limit resets instead
+	// of failing, however long that takes.
+	WaitForRateLimit bool
+	// RequestTimeout bounds how long a request may wait for response
+	// headers. Zero means no limit.
+	RequestTimeout time.Duration
+	// Host is the GitHub instance to talk to. The zero value is github.com.
+	Host Host
+	// Quiet stops the client reporting rate-limit waits on stderr.
+	Quiet bool
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{Retries: DefaultRetries, RequestTimeout: DefaultRequestTimeout}
+}
+
 type GitHubClient struct {
 	client *github.Client
+	host   Host
+	// source supplies the token, if any; for an app installation it
+	// refreshes it when it expires.
+	source         oauth2.TokenSource
+	retry          retryPolicy
+	requestTimeout time.Duration
 }
 
 func NewGitHubClient() *GitHubClient {
-	var client *github.Client
+	return NewGitHubClientWithOptions(DefaultClientOptions())
+}
 
-	authToken := os.Getenv("PGIT_GITHUB_TOKEN")
+func NewGitHubClientWithOptions(opts ClientOptions) *GitHubClient {
+	host := opts.Host
+	if !host.IsEnterprise() {
+		host = DotCom
+	}
 
-	if authToken == "" {
-		tokenManager := token.NewManager()
+	var source oauth2.TokenSource
+	if app := appAuth.Load(); app != nil {
+		source = app.tokenSource(host)
+	} else {
+		tokenManager := token.NewManagerForHost(host.Name)
 		if tokenManager.TokenExists() {
 			if t, err := tokenManager.GetToken(); err == nil {
-				authToken = t
+				source = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: t})
 			}
 		}
 	}
 
-	if authToken != "" {
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: authToken},
-		)
-		tc := oauth2.NewClient(context.Background(), ts)
-		client = github.NewClient(tc)
-	} else {
-		client = github.NewClient(nil)
+	policy := retryPolicy{retries: opts.Retries, waitForRateLimit: opts.WaitForRateLimit, quiet: opts.Quiet}
+	var transport http.RoundTripper = &retryTransport{base: countingTransport{base: newTransport(opts.RequestTimeout)}, policy: policy}
+
+	if source != nil {
+		transport = &oauth2.Transport{Source: source, Base: transport}
 	}
 
-	return &GitHubClient{client: client}
+	client := github.NewClient(&http.Client{Transport: transport})
+	if host.IsEnterprise() {
+		// BaseURL was parsed by ParseHost, so this cannot fail.
+		client, _ = client.WithEnterpriseURLs(host.BaseURL, host.BaseURL)
+	}
+
+	return &GitHubClient{
+		client:         client,
+		host:           host,
+		source:         source,
+		retry:          policy,
+		requestTimeout: opts.RequestTimeout,
+	}
+}
+
+func newTransport(requestTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = requestTimeout
+	return transport
+}
+
+type apiCallsKey struct{}
+
+// CountAPICalls returns a context under which every API request, including
+// each retry, adds one to n. A Download counts its own calls into
+// Result.APICalls; this covers calls made before it, such as ResolveRef.
+func CountAPICalls(ctx context.Context, n *atomic.Int64) context.Context {
+	return context.WithValue(ctx, apiCallsKey{}, n)
+}
+
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if n, ok := req.Context().Value(apiCallsKey{}).(*atomic.Int64); ok {
+		n.Add(1)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// authorize adds the client's token to requests made outside go-github, such
+// as raw file downloads, so private repositories work there too.
+func (gc *GitHubClient) authorize(req *http.Request) {
+	if gc.source == nil {
+		return
+	}
+	if t, err := gc.source.Token(); err == nil {
+		req.Header.Set("Authorization", "token "+t.AccessToken)
+	}
 }
 
 func (gc *GitHubClient) GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, error) {
-	fileContent, directoryContent, _, err := gc.client.Repositories.GetContents(ctx, owner, repo, path, opts)
+	var fileContent *github.RepositoryContent
+	var directoryContent []*github.RepositoryContent
+	err := gc.waitForRateLimit(ctx, func() (err error) {
+		fileContent, directoryContent, _, err = gc.client.Repositories.GetContents(ctx, owner, repo, path, opts)
+		return err
+	})
 	return fileContent, directoryContent, err
 }
 
+func (gc *GitHubClient) GetCommitSHA(ctx context.Context, owner, repo, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	var sha string
+	err := gc.waitForRateLimit(ctx, func() (err error) {
+		sha, _, err = gc.client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, "")
+		return err
+	})
+	return sha, err
+}
+
+// ListMatchingRefs returns the full names of every ref starting with prefix,
+// e.g. "heads/feature" matches refs/heads/feature and refs/heads/feature/x.
+func (gc *GitHubClient) ListMatchingRefs(ctx context.Context, owner, repo, prefix string) ([]string, error) {
+	opts := &github.ReferenceListOptions{Ref: prefix, ListOptions: github.ListOptions{PerPage: 100}}
+
+	var names []string
+	for {
+		var refs []*github.Reference
+		var resp *github.Response
+		err := gc.waitForRateLimit(ctx, func() (err error) {
+			refs, resp, err = gc.client.Git.ListMatchingRefs(ctx, owner, repo, opts)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+			names = append(names, ref.GetRef())
+		}
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (gc *GitHubClient) GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*github.Tree, error) {
+	var tree *github.Tree
+	err := gc.waitForRateLimit(ctx, func() (err error) {
+		tree, _, err = gc.client.Git.GetTree(ctx, owner, repo, sha, recursive)
+		return err
+	})
+	return tree, err
+}
+
+func (gc *GitHubClient) GetBlobRaw(ctx context.Context, owner, repo, sha string) ([]byte, error) {
+	var blob []byte
+	err := gc.waitForRateLimit(ctx, func() (err error) {
+		blob, _, err = gc.client.Git.GetBlobRaw(ctx, owner, repo, sha)
+		return err
+	})
+	return blob, err
+}
+
+func (gc *GitHubClient) GetArchiveLink(ctx context.Context, owner, repo, ref string) (*url.URL, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+
+	var link *url.URL
+	err := gc.waitForRateLimit(ctx, func() (err error) {
+		link, _, err = gc.client.Repositories.GetArchiveLink(ctx, owner, repo, github.Tarball, opts, 1)
+		return err
+	})
+	return link, err
+}
+
 func (gc *GitHubClient) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, error) {
-	repository, _, err := gc.client.Repositories.Get(ctx, owner, repo)
+	var repository *github.Repository
+	err := gc.waitForRateLimit(ctx, func() (err error) {
+		repository, _, err = gc.client.Repositories.Get(ctx, owner, repo)
+		return err
+	})
 	return repository, err
 }
 
@@ -60,3 +221,87 @@ func (gc *GitHubClient) GetAuthenticatedUser(ctx context.Context) (*github.User,
 	user, _, err := gc.client.Users.Get(ctx, "")
 	return user, err
 }
+
+// AuthInfo is what GitHub reports about the client's credentials.
+type AuthInfo struct {
+	User *github.User
+	// Scopes lists a classic token's OAuth scopes. It is nil when GitHub
+	// sends no X-OAuth-Scopes header, as for fine-grained tokens.
+	Scopes []string
+	// Expires is when the token stops working, from the
+	// GitHub-Authentication-Token-Expiration header. It is zero for tokens
+	// without an expiry date.
+	Expires time.Time
+}
+
+func (gc *GitHubClient) GetAuthInfo(ctx context.Context) (*AuthInfo, error) {
+	user, resp, err := gc.client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &AuthInfo{User: user}
+	if header, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		info.Scopes = []string{}
+		for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				info.Scopes = append(info.Scopes, scope)
+			}
+		}
+	}
+	info.Expires = parseTokenExpiration(resp.Header.Get("GitHub-Authentication-Token-Expiration"))
+	return info, nil
+}
+
+// parseTokenExpiration reads dates such as "2024-03-13 16:19:32 UTC"; GitHub
+// has also sent numeric zones.
+func parseTokenExpiration(header string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(header)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// RepoAccess is what a repository lookup revealed about the client's access
+// to it.
+type RepoAccess struct {
+	// Repository is nil when the lookup failed.
+	Repository *github.Repository
+	// Status is the HTTP status of the lookup, or 0 if there was no
+	// response.
+	Status int
+	// OwnerFound reports, after a 404, whether the owner exists. GitHub
+	// answers 404 rather than 403 for private repositories a token cannot
+	// see, so a missing owner is the only sure sign of a typo.
+	OwnerFound bool
+	// SSO is the X-GitHub-SSO header of a 403: the organization uses SAML
+	// single sign-on and the token has not been authorized for it.
+	SSO string
+	Err error
+}
+
+// CheckRepositoryAccess looks the repository up and, when that fails,
+// gathers what is needed to explain why.
+func (gc *GitHubClient) CheckRepositoryAccess(ctx context.Context, owner, repo string) *RepoAccess {
+	access := &RepoAccess{}
+	repository, resp, err := gc.client.Repositories.Get(ctx, owner, repo)
+	if resp != nil {
+		access.Status = resp.StatusCode
+	}
+	if err == nil {
+		access.Repository = repository
+		return access
+	}
+
+	access.Err = err
+	switch access.Status {
+	case http.StatusNotFound:
+		_, _, err := gc.client.Users.Get(ctx, owner)
+		access.OwnerFound = err == nil
+	case http.StatusForbidden:
+		access.SSO = resp.Header.Get("X-GitHub-SSO")
+	}
+	return access
+}
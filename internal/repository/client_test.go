@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTokenExpiration(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Time
+	}{
+		{"2024-03-13 16:19:32 UTC", time.Date(2024, 3, 13, 16, 19, 32, 0, time.UTC)},
+		{" 2024-03-13 16:19:32 UTC ", time.Date(2024, 3, 13, 16, 19, 32, 0, time.UTC)},
+		{"2024-03-13 16:19:32 +0100", time.Date(2024, 3, 13, 15, 19, 32, 0, time.UTC)},
+		{"", time.Time{}},
+		{"next tuesday", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := parseTokenExpiration(tt.header); !got.Equal(tt.want) {
+			t.Errorf("parseTokenExpiration(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
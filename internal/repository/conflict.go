@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ConflictPolicy decides what happens when a file is already present at the
+// local path it would be written to.
+type ConflictPolicy int
+
+const (
+	// ConflictOverwrite replaces existing files.
+	ConflictOverwrite ConflictPolicy = iota
+	// ConflictSkip leaves existing files untouched.
+	ConflictSkip
+	// ConflictFail treats an existing file as a failure.
+	ConflictFail
+	// ConflictUpdate re-downloads a file only when its git blob SHA differs
+	// from the one in the listing.
+	ConflictUpdate
+)
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictSkip:
+		return "skip-existing"
+	case ConflictFail:
+		return "fail-on-existing"
+	case ConflictUpdate:
+		return "update"
+	default:
+		return "overwrite"
+	}
+}
+
+// checkExisting applies the conflict policy to localPath before anything is
+// fetched. file.SHA is the blob SHA from the listing, if one is known; without
+// it ConflictUpdate has nothing to compare and falls back to overwriting.
+func (d *Downloader) checkExisting(localPath string, file remoteFile) error {
+	info, err := os.Lstat(localPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", localPath, err)
+	}
+
+	switch d.conflict {
+	case ConflictSkip:
+		return &skipError{reason: "already exists"}
+	case ConflictFail:
+		return fmt.Errorf("%s already exists", localPath)
+	case ConflictUpdate:
+		var localSHA string
+		switch {
+		case file.SHA == "":
+			return nil
+		case file.Type == "symlink" && info.Mode()&fs.ModeSymlink != 0:
+			// Git stores a symlink as a blob holding its target.
+			target, err := os.Readlink(localPath)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink %s: %w", localPath, err)
+			}
+			localSHA = blobSHA([]byte(target))
+		case file.Type != "symlink" && info.Mode().IsRegular():
+			localSHA, err = gitBlobSHA(localPath)
+			if err != nil {
+				return fmt.Errorf("failed to hash %s: %w", localPath, err)
+			}
+		default:
+			return nil
+		}
+
+		if localSHA != file.SHA {
+			return nil
+		}
+
+		// The content matches, but the executable bit may have changed.
+		if file.Mode != "" && file.Type != "symlink" && info.Mode().Perm() != fileMode(file.Mode) {
+			if err := os.Chmod(localPath, fileMode(file.Mode)); err != nil {
+				return fmt.Errorf("failed to set mode on %s: %w", localPath, err)
+			}
+		}
+		return &skipError{reason: "unchanged"}
+	}
+
+	return nil
+}
+
+// gitBlobSHA computes the object ID git would give the file's contents, which
+// is what GitHub reports as the SHA of every file in a listing.
+func gitBlobSHA(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", info.Size())
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func blobSHA(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
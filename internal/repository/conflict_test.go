@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// conflictFixture serves four files, of which the output directory already
+// holds two unchanged (one with the wrong mode) and one edited.
+func conflictFixture(t *testing.T) (*fakeGitHub, *rawFetches, string) {
+	t.Helper()
+
+	f := newFakeGitHub(t, map[string]string{
+		"same.txt":    "same\n",
+		"run.sh":      "#!/bin/sh\n",
+		"changed.txt": "new\n",
+		"new.txt":     "fresh\n",
+	})
+	fetches := &rawFetches{}
+	f.raw = fetches.serve(f)
+
+	dir := t.TempDir()
+	local := map[string]string{"same.txt": "same\n", "run.sh": "#!/bin/sh\n", "changed.txt": "old\n"}
+	if err := os.MkdirAll(filepath.Join(dir, testRepo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range local {
+		if err := os.WriteFile(filepath.Join(dir, testRepo, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f, fetches, dir
+}
+
+func statuses(result *Result) map[string]FileStatus {
+	got := make(map[string]FileStatus)
+	for _, fr := range result.Files() {
+		got[fr.Path] = fr.Status
+	}
+	return got
+}
+
+func TestConflictSkipExisting(t *testing.T) {
+	f, fetches, dir := conflictFixture(t)
+
+	result, _, err := f.download(t, "", Options{OutputDir: dir, Conflict: ConflictSkip})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	if got := fetches.sorted(); !slices.Equal(got, []string{"new.txt"}) {
+		t.Errorf("fetched %v, want only new.txt", got)
+	}
+	if got := readFile(t, dir, filepath.Join(testRepo, "changed.txt")); got != "old\n" {
+		t.Errorf("changed.txt = %q, want it left alone", got)
+	}
+	if got := statuses(result)["changed.txt"]; got != StatusSkipped {
+		t.Errorf("changed.txt status = %s, want skipped", got)
+	}
+}
+
+func TestConflictFailOnExisting(t *testing.T) {
+	f, _, dir := conflictFixture(t)
+
+	result, _, err := f.download(t, "", Options{OutputDir: dir, Conflict: ConflictFail, KeepGoing: true})
+	if err == nil {
+		t.Fatal("Download succeeded, want an error for the existing files")
+	}
+
+	want := map[string]FileStatus{
+		"same.txt":    StatusFailed,
+		"run.sh":      StatusFailed,
+		"changed.txt": StatusFailed,
+		"new.txt":     StatusDownloaded,
+	}
+	if got := statuses(result); !maps.Equal(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if got := readFile(t, dir, filepath.Join(testRepo, "changed.txt")); got != "old\n" {
+		t.Errorf("changed.txt = %q, want it left alone", got)
+	}
+}
+
+func TestConflictUpdate(t *testing.T) {
+	for _, opts := range []Options{
+		{ListMode: ListTrees, Conflict: ConflictUpdate},
+		{ListMode: ListContents, Conflict: ConflictUpdate},
+	} {
+		t.Run(opts.ListMode.String(), func(t *testing.T) {
+			f, fetches, dir := conflictFixture(t)
+			opts.OutputDir = dir
+
+			result, _, err := f.download(t, "", opts)
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+
+			if got := fetches.sorted(); !slices.Equal(got, []string{"changed.txt", "new.txt"}) {
+				t.Errorf("fetched %v, want changed.txt and new.txt", got)
+			}
+			if got := readFile(t, dir, filepath.Join(testRepo, "changed.txt")); got != "new\n" {
+				t.Errorf("changed.txt = %q, want the new content", got)
+			}
+			if got := statuses(result)["same.txt"]; got != StatusSkipped {
+				t.Errorf("same.txt status = %s, want skipped", got)
+			}
+
+			info, err := os.Stat(filepath.Join(dir, testRepo, "run.sh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("run.sh mode = %v, want 0755 on an unchanged file", info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-github/v57/github"
@@ -16,8 +20,76 @@ import (
 
 var (
 	ErrTookTooLong = errors.New("download took too long")
+	ErrIncomplete  = errors.New("download incomplete")
 )
 
+const (
+	DefaultJobs     = 8
+	DefaultListJobs = 4
+)
+
+type Options struct {
+	Quiet    bool
+	ListMode ListMode
+	Mode     DownloadMode
+	Jobs     int // concurrent raw file downloads
+	ListJobs int // concurrent API listing calls
+
+	Client ClientOptions
+
+	// Timeout bounds the whole download. Zero means no deadline.
+	Timeout time.Duration
+	// StallTimeout fails a transfer that receives no bytes for this long.
+	// Zero disables it.
+	StallTimeout time.Duration
+
+	// KeepGoing records failures and carries on with the remaining files
+	// instead of cancelling the run at the first one.
+	KeepGoing bool
+
+	// OutputDir is the directory everything is written under. Empty means
+	// the current directory.
+	OutputDir string
+	// StripComponents drops this many leading path components, like tar's
+	// option of the same name. Files with too few components are skipped.
+	StripComponents int
+	// Flat writes every file directly into OutputDir.
+	Flat bool
+
+	// Conflict decides what to do with files that already exist locally.
+	Conflict ConflictPolicy
+
+	// Submodules decides whether submodules are skipped, recorded as empty
+	// directories or fetched at their pinned commit.
+	Submodules SubmodulePolicy
+
+	// Filter limits which files are listed and downloaded.
+	Filter Filter
+
+	// DryRun lists everything a download would fetch, recording each file
+	// as StatusListed, without writing anything.
+	DryRun bool
+
+	// OnResult, if set, is called with each path's outcome as soon as it is
+	// recorded, from whichever worker recorded it.
+	OnResult func(FileResult)
+
+	// budget is shared by every Downloader working on one run, so that
+	// batches and submodules stay within Jobs and ListJobs overall.
+	budget *budget
+	// progress is shared the same way, so that a run draws a single bar.
+	progress *progress
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Jobs:         DefaultJobs,
+		ListJobs:     DefaultListJobs,
+		Client:       DefaultClientOptions(),
+		StallTimeout: DefaultStallTimeout,
+	}
+}
+
 type Downloader struct {
 	client          *GitHubClient
 	httpClient      *http.Client
@@ -25,24 +97,77 @@ type Downloader struct {
 	repo            string
 	basePath        string
 	branch          string
+	commitSHA       string
+	listMode        ListMode
+	mode            DownloadMode
+	jobs            int
+	listJobs        int
+	keepGoing       bool
+	timeout         time.Duration
+	stallTimeout    time.Duration
+	outputDir       string
+	stripComponents int
+	flat            bool
+	claimed         map[string]string
+	conflict        ConflictPolicy
+	submodules      SubmodulePolicy
+	listed          map[string]remoteFile
+	filter          Filter
+	budget          *budget
+	dryRun          bool
+	apiCalls        atomic.Int64
 	quiet           bool
-	downloadedCount int
-	totalCount      int
+	onResult        func(FileResult)
+	progress        *progress
 	mu              sync.Mutex
+
+	// Submodule fetches run a child Downloader with the same options.
+	opts           Options
+	depth          int
+	parent         *Downloader
+	gitmodulesOnce sync.Once
+	gitmodules     map[string]string
+	gitmodulesErr  error
+
+	// modeWarning reports once that the Contents API listing could not be
+	// given file modes.
+	modeWarning sync.Once
+
+	pending   sync.WaitGroup
+	dirs      *workQueue[string]
+	files     *workQueue[remoteFile]
+	result    *Result
+	cancel    context.CancelFunc
+	abortOnce sync.Once
+	abortErr  error
 }
 
 func NewDownloader(client *GitHubClient, owner, repo, basePath, branch string) *Downloader {
-	return NewDownloaderWithOptions(client, owner, repo, basePath, branch, false)
+	return NewDownloaderWithOptions(client, owner, repo, basePath, branch, DefaultOptions())
 }
 
-func NewDownloaderWithOptions(client *GitHubClient, owner, repo, basePath, branch string, quiet bool) *Downloader {
+func NewDownloaderWithOptions(client *GitHubClient, owner, repo, basePath, branch string, opts Options) *Downloader {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
+
+	listJobs := opts.ListJobs
+	if listJobs <= 0 {
+		listJobs = DefaultListJobs
+	}
+
+	transport := newTransport(client.requestTimeout)
+	transport.MaxIdleConnsPerHost = jobs
+
+	policy := client.retry
+	policy.quiet = policy.quiet || opts.Quiet
 	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
+		Transport: &retryTransport{base: transport, policy: policy},
+	}
+
+	if opts.budget == nil {
+		opts.budget = newBudget(jobs, listJobs)
 	}
 
 	return &Downloader{
@@ -52,157 +177,559 @@ func NewDownloaderWithOptions(client *GitHubClient, owner, repo, basePath, branc
 		repo:       repo,
 		basePath:   basePath,
 		branch:     branch,
+		listMode:   opts.ListMode,
+		mode:       opts.Mode,
+		jobs:       jobs,
+		listJobs:   listJobs,
+		keepGoing:  opts.KeepGoing,
+
+		timeout:      opts.Timeout,
+		stallTimeout: opts.StallTimeout,
+
+		outputDir:       opts.OutputDir,
+		stripComponents: opts.StripComponents,
+		flat:            opts.Flat,
+		claimed:         make(map[string]string),
+		conflict:        opts.Conflict,
+		submodules:      opts.Submodules,
+		filter:          opts.Filter,
+		budget:          opts.budget,
+		dryRun:          opts.DryRun,
+		quiet:           opts.Quiet,
+		onResult:        opts.OnResult,
+		progress:        opts.progress,
+
+		opts: opts,
 	}
 }
 
-func (d *Downloader) Download(ctx context.Context) error {
-	wg := &sync.WaitGroup{}
-	errCh := make(chan error, 1)
-
-	timeoutCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+// Download runs a fixed pool of listing workers and file workers over two
+// queues. Listing workers turn directories into more directories and files;
+// file workers fetch them. The run ends when every queued job is done, and the
+// Result accounts for every path whether or not an error is returned.
+func (d *Downloader) Download(ctx context.Context) (*Result, error) {
+	d.result = &Result{}
+	d.dirs = newWorkQueue[string]()
+	d.files = newWorkQueue[remoteFile]()
+
+	var timeoutCtx context.Context
+	var cancel context.CancelFunc
+	if d.timeout > 0 {
+		timeoutCtx, cancel = context.WithTimeout(ctx, d.timeout)
+	} else {
+		timeoutCtx, cancel = context.WithCancel(ctx)
+	}
 	defer cancel()
 
-	wg.Add(1)
-	go d.downloadContents(timeoutCtx, wg, d.basePath, errCh)
+	runCtx, abort := context.WithCancel(timeoutCtx)
+	defer abort()
+	d.cancel = abort
 
-	go func() {
-		wg.Wait()
-		close(errCh)
-	}()
+	// A fetched submodule's calls are counted against the top-level run.
+	if d.parent == nil {
+		runCtx = CountAPICalls(runCtx, &d.apiCalls)
+	}
 
-	select {
-	case err := <-errCh:
+	if d.progress == nil && !d.quiet {
+		d.progress = newProgress()
+		d.progress.start()
+		defer d.progress.stop()
+	}
+
+	workers := &sync.WaitGroup{}
+	for i := 0; i < d.listJobs; i++ {
+		workers.Add(1)
+		go d.listWorker(runCtx, workers)
+	}
+	for i := 0; i < d.jobs; i++ {
+		workers.Add(1)
+		go d.fileWorker(runCtx, workers)
+	}
+
+	switch err := d.resolveCommit(runCtx); {
+	case err != nil:
+		d.record(runCtx, remoteFile{Path: d.basePath}, "", err)
+	case d.mode == ModeArchive && d.listMode == ListContents && !d.dryRun:
+		d.pending.Add(1)
+		go d.streamArchive(runCtx)
+	default:
+		d.enqueueDir(d.basePath)
+	}
+
+	d.pending.Wait()
+	d.dirs.close()
+	d.files.close()
+	workers.Wait()
+	d.result.Commit = d.commitSHA
+	d.result.APICalls = d.apiCalls.Load()
+
+	switch {
+	case timeoutCtx.Err() == context.DeadlineExceeded:
+		return d.result, ErrTookTooLong
+	case timeoutCtx.Err() != nil:
+		return d.result, timeoutCtx.Err()
+	case d.abortErr != nil:
+		return d.result, d.abortErr
+	}
+
+	if failed := len(d.result.Failed()); failed > 0 {
+		return d.result, fmt.Errorf("%w: %d path(s) failed", ErrIncomplete, failed)
+	}
+
+	return d.result, nil
+}
+
+// resolveCommit pins the run to the commit the ref points at right now. Every
+// later listing, raw download and archive request uses that SHA, so a branch
+// that moves mid-download cannot produce a tree mixing two commits.
+func (d *Downloader) resolveCommit(ctx context.Context) error {
+	if fullSHAPattern.MatchString(d.branch) {
+		d.commitSHA = d.branch
+		return nil
+	}
+
+	if err := d.budget.list.acquire(ctx); err != nil {
 		return err
-	case <-timeoutCtx.Done():
-		if timeoutCtx.Err() == context.DeadlineExceeded {
-			return ErrTookTooLong
+	}
+	commitSHA, err := d.client.GetCommitSHA(ctx, d.owner, d.repo, d.branch)
+	d.budget.list.release()
+	if err != nil {
+		if d.branch == "" {
+			return fmt.Errorf("failed to resolve the default branch of %s/%s: %w", d.owner, d.repo, err)
 		}
-		return timeoutCtx.Err()
+		return fmt.Errorf("failed to resolve ref '%s': %w", d.branch, err)
 	}
+	d.commitSHA = commitSHA
+
+	if d.parent == nil {
+		d.logf("Commit: %s\n", commitSHA)
+	}
+	return nil
 }
 
-func (d *Downloader) downloadContents(ctx context.Context, wg *sync.WaitGroup, path string, errCh chan error) {
-	defer wg.Done()
+func (d *Downloader) enqueueDir(path string) {
+	d.pending.Add(1)
+	d.dirs.push(path)
+}
 
-	select {
-	case <-ctx.Done():
-		d.sendError(errCh, ctx.Err())
+func (d *Downloader) enqueueFile(file remoteFile) {
+	d.progress.expect(file)
+	d.pending.Add(1)
+	d.files.push(file)
+}
+
+func (d *Downloader) listWorker(ctx context.Context, workers *sync.WaitGroup) {
+	defer workers.Done()
+
+	for {
+		path, ok := d.dirs.pop()
+		if !ok {
+			return
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			d.record(ctx, remoteFile{Path: path}, "", ctx.Err())
+		case d.listMode == ListContents:
+			d.downloadContents(ctx, path)
+		default:
+			d.downloadTree(ctx)
+		}
+		d.pending.Done()
+	}
+}
+
+func (d *Downloader) fileWorker(ctx context.Context, workers *sync.WaitGroup) {
+	defer workers.Done()
+
+	for {
+		file, ok := d.files.pop()
+		if !ok {
+			return
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			d.record(ctx, file, "", ctx.Err())
+		case d.dryRun:
+			d.listFile(ctx, file)
+		default:
+			localPath, err := d.downloadFile(ctx, file)
+			d.record(ctx, file, localPath, err)
+		}
+		d.progress.finish()
+		d.pending.Done()
+	}
+}
+
+// listFile stands in for downloadFile on a dry run: it works out where the
+// file would go and records it as listed without touching the disk. Fetched
+// submodules are still walked so that their files are listed too.
+func (d *Downloader) listFile(ctx context.Context, file remoteFile) {
+	localPath, err := d.getExactPath(d.basePath, file.Path)
+	if err != nil {
+		d.record(ctx, file, "", fmt.Errorf("failed to determine local path for %s: %w", file.Path, err))
 		return
-	default:
 	}
 
-	opts := &github.RepositoryContentGetOptions{}
-	if d.branch != "" {
-		opts.Ref = d.branch
+	if file.Type == "submodule" && d.submodules == SubmodulesFetch {
+		if err := d.downloadSubmodule(ctx, file, localPath); err != nil {
+			d.record(ctx, file, localPath, err)
+			return
+		}
 	}
 
-	fileContent, directoryContent, err := d.client.GetContents(ctx, d.owner, d.repo, path, opts)
+	d.add(FileResult{
+		Path:      file.Path,
+		SHA:       file.SHA,
+		Type:      file.Type,
+		Size:      int64(file.Size),
+		LocalPath: localPath,
+		Status:    StatusListed,
+	})
+}
+
+func (d *Downloader) downloadContents(ctx context.Context, dir string) {
+	if err := d.budget.list.acquire(ctx); err != nil {
+		d.record(ctx, remoteFile{Path: dir}, "", err)
+		return
+	}
+
+	opts := &github.RepositoryContentGetOptions{Ref: d.commitSHA}
+	fileContent, directoryContent, err := d.client.GetContents(ctx, d.owner, d.repo, dir, opts)
+	d.budget.list.release()
 	if err != nil {
-		d.sendError(errCh, fmt.Errorf("failed to get contents for path '%s': %w", path, err))
+		d.record(ctx, remoteFile{Path: dir}, "", fmt.Errorf("failed to get contents for path '%s': %w", dir, err))
 		return
 	}
 
 	if fileContent != nil {
-		if err := d.downloadFile(ctx, fileContent.GetDownloadURL(), fileContent.GetPath()); err != nil {
-			d.sendError(errCh, err)
+		if file := contentFile(fileContent); d.wanted(ctx, file) {
+			file.Mode = d.fileModes(ctx, path.Dir(file.Path))[file.Path]
+			d.enqueueFile(file)
 		}
 		return
 	}
 
-	if directoryContent != nil {
-		d.processDirectoryContents(ctx, wg, directoryContent, errCh)
+	var modes map[string]string
+	for _, content := range directoryContent {
+		switch content.GetType() {
+		case "dir":
+			if d.filter.descends(d.relPath(content.GetPath())) {
+				d.enqueueDir(content.GetPath())
+			}
+		default:
+			file := contentFile(content)
+			if !d.wanted(ctx, file) {
+				continue
+			}
+			if modes == nil {
+				modes = d.fileModes(ctx, dir)
+			}
+			file.Mode = modes[file.Path]
+			d.enqueueFile(file)
+		}
 	}
 }
 
-func (d *Downloader) processDirectoryContents(ctx context.Context, wg *sync.WaitGroup, contents []*github.RepositoryContent, errCh chan error) {
-	for _, content := range contents {
-		select {
-		case <-ctx.Done():
-			d.sendError(errCh, ctx.Err())
-			return
-		default:
-		}
+// fileModes looks up the git modes of the entries in dir, which the Contents
+// API leaves out, so that executables stay executable. It costs one more call
+// per directory. Without it files get the default permissions, and a warning
+// says so once.
+func (d *Downloader) fileModes(ctx context.Context, dir string) map[string]string {
+	if dir == "." {
+		dir = ""
+	}
 
-		wg.Add(1)
-		go func(content *github.RepositoryContent) {
-			defer wg.Done()
+	// The Trees API accepts "<commit>:<path>" for the tree at path.
+	treeish := d.commitSHA
+	if dir != "" {
+		treeish += ":" + dir
+	}
 
-			switch content.GetType() {
-			case "file":
-				if err := d.downloadFile(ctx, content.GetDownloadURL(), content.GetPath()); err != nil {
-					d.sendError(errCh, err)
-				}
-			case "dir":
-				wg.Add(1)
-				go d.downloadContents(ctx, wg, content.GetPath(), errCh)
-			}
-		}(content)
+	modes := make(map[string]string)
+	if err := d.budget.list.acquire(ctx); err != nil {
+		return modes
 	}
+	tree, err := d.client.GetTree(ctx, d.owner, d.repo, treeish, false)
+	d.budget.list.release()
+	if err != nil {
+		d.modeWarning.Do(func() {
+			d.logf("Warning: could not read file modes, executable bits will not be preserved: %v\n", err)
+		})
+		return modes
+	}
+
+	for _, entry := range tree.Entries {
+		modes[path.Join(dir, entry.GetPath())] = entry.GetMode()
+	}
+	return modes
 }
 
-func (d *Downloader) downloadFile(ctx context.Context, downloadURL, path string) error {
+func contentFile(content *github.RepositoryContent) remoteFile {
+	file := remoteFile{
+		Path:         content.GetPath(),
+		SHA:          content.GetSHA(),
+		Size:         content.GetSize(),
+		Type:         content.GetType(),
+		DownloadURL:  content.GetDownloadURL(),
+		SubmoduleURL: content.GetSubmoduleGitURL(),
+	}
+
+	// Directory listings report submodules as files without a download URL.
+	if file.Type == "file" && file.DownloadURL == "" {
+		file.Type = "submodule"
+	}
+	return file
+}
+
+func (d *Downloader) downloadFile(ctx context.Context, file remoteFile) (string, error) {
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return "", ctx.Err()
 	default:
 	}
 
-	fmt.Printf("Downloading: %s\n", path)
+	path := file.Path
+	localPath, err := d.getExactPath(d.basePath, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine local path for %s: %w", path, err)
+	}
+
+	// A submodule's files are checked individually by its own downloader.
+	if file.Type == "submodule" {
+		return localPath, d.downloadSubmodule(ctx, file, localPath)
+	}
+
+	if err := d.checkExisting(localPath, file); err != nil {
+		return localPath, err
+	}
+
+	if err := d.budget.fetch.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer d.budget.fetch.release()
+
+	if file.Type == "symlink" {
+		target, err := d.client.GetBlobRaw(ctx, d.owner, d.repo, file.SHA)
+		if err != nil {
+			return "", fmt.Errorf("failed to get symlink target for %s: %w", path, err)
+		}
+
+		d.logf("Linking: %s -> %s\n", path, target)
+		return localPath, d.writeSymlink(localPath, string(target))
+	}
+
+	d.logf("Downloading: %s\n", path)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", file.DownloadURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request for %s: %w", path, err)
+		return "", fmt.Errorf("failed to create request for %s: %w", path, err)
 	}
+	d.client.authorize(req)
+	// Only the Contents API URLs used on Enterprise Server look at this.
+	req.Header.Set("Accept", "application/vnd.github.raw")
 
-	resp, err := d.httpClient.Do(req)
+	resp, err := d.get(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to download %s: %w", path, err)
+		return "", fmt.Errorf("failed to download %s: %w", path, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download %s: received HTTP %d %s", path, resp.StatusCode, http.StatusText(resp.StatusCode))
+		return "", fmt.Errorf("failed to download %s: received HTTP %d %s", path, resp.StatusCode, http.StatusText(resp.StatusCode))
 	}
 
-	localPath, err := d.getExactPath(d.basePath, path)
-	if err != nil {
-		return fmt.Errorf("failed to determine local path for %s: %w", path, err)
+	return localPath, d.writeFile(localPath, d.progress.reader(resp.Body), fileMode(file.Mode))
+}
+
+// fileMode maps a git tree mode to local permissions. Git only tracks the
+// executable bit, so everything else gets the usual 0644.
+func fileMode(gitMode string) os.FileMode {
+	if gitMode == executableMode {
+		return 0755
+	}
+	return 0644
+}
+
+func (d *Downloader) writeFile(localPath string, r io.Reader, perm os.FileMode) error {
+	if err := d.checkParents(localPath); err != nil {
+		return err
 	}
 
 	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
 		return fmt.Errorf("failed to create directory structure for %s: %w", localPath, err)
 	}
 
-	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	// Never write through a symlink left by an earlier download; it could
+	// point anywhere.
+	if info, err := os.Lstat(localPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(localPath); err != nil {
+			return fmt.Errorf("failed to replace symlink %s: %w", localPath, err)
+		}
+	}
+
+	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 	if err != nil {
 		return fmt.Errorf("failed to create file %s: %w", localPath, err)
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	_, err = io.Copy(file, r)
 	if err != nil {
 		return fmt.Errorf("failed to write file %s: %w", localPath, err)
 	}
 
+	// OpenFile only applies perm to new files.
+	if err := file.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set mode on %s: %w", localPath, err)
+	}
+
 	return nil
 }
 
-func (d *Downloader) getExactPath(base, path string) (string, error) {
-	if base == "" {
-		return filepath.Join(d.repo, path), nil
+// writeSymlink recreates a symlink, refusing targets that would resolve
+// outside the output directory.
+func (d *Downloader) writeSymlink(localPath, target string) error {
+	if err := d.checkParents(localPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory structure for %s: %w", localPath, err)
 	}
 
-	relPath, err := filepath.Rel(base, path)
+	if filepath.IsAbs(target) || !d.insideRoot(filepath.Dir(localPath), target) {
+		return &skipError{reason: fmt.Sprintf("symlink target %q is outside the output directory", target)}
+	}
+
+	if err := os.Remove(localPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to replace %s: %w", localPath, err)
+	}
+
+	if err := os.Symlink(target, localPath); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", localPath, err)
+	}
+
+	return nil
+}
+
+func (d *Downloader) root() string {
+	if d.outputDir == "" {
+		return "."
+	}
+	return d.outputDir
+}
+
+// checkParents refuses to write localPath if any directory between it and
+// the output root is a symlink. MkdirAll and OpenFile would follow it, so a
+// link from this run or an earlier one could carry the write elsewhere.
+func (d *Downloader) checkParents(localPath string) error {
+	rel, err := filepath.Rel(d.root(), filepath.Dir(localPath))
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("refusing to write %s outside the output directory", localPath)
+	}
+
+	dir := d.root()
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", dir, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write %s through symlinked directory %s", localPath, dir)
+		}
+	}
+	return nil
+}
+
+// insideRoot reports whether target, read relative to dir, resolves inside
+// the output root. Links already on disk are followed one component at a
+// time, the way the OS would, so a chain of individually harmless links
+// cannot lead outside.
+func (d *Downloader) insideRoot(dir, target string) bool {
+	root, err := filepath.EvalSymlinks(d.root())
 	if err != nil {
-		return "", err
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+
+	for _, part := range strings.Split(target, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		resolved = filepath.Join(resolved, part)
+		info, err := os.Lstat(resolved)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			// Missing components hold no links, so the rest resolves by
+			// name alone.
+			continue
+		}
+		if resolved, err = filepath.EvalSymlinks(resolved); err != nil {
+			return false
+		}
 	}
 
-	return filepath.Join(filepath.Base(base), relPath), nil
+	rel, err := filepath.Rel(root, resolved)
+	return err == nil && filepath.IsLocal(rel)
 }
 
-func (d *Downloader) sendError(errCh chan error, err error) {
-	select {
-	case errCh <- err:
+func (d *Downloader) add(fr FileResult) {
+	d.result.add(fr)
+	if d.onResult != nil {
+		d.onResult(fr)
+	}
+}
+
+// logf prints a log line unless the downloader is quiet.
+func (d *Downloader) logf(format string, args ...any) {
+	if !d.quiet {
+		d.progress.printf(format, args...)
+	}
+}
+
+// record files the outcome for path. Deliberate skips and errors caused by the
+// run being cancelled count as skips, not failures; a real failure cancels the run unless
+// keepGoing is set.
+func (d *Downloader) record(ctx context.Context, file remoteFile, localPath string, err error) {
+	fr := FileResult{Path: file.Path, SHA: file.SHA, Type: file.Type, Size: int64(file.Size), LocalPath: localPath}
+
+	var skip *skipError
+	switch {
+	case err == nil:
+		fr.Status = StatusDownloaded
+	case errors.As(err, &skip):
+		fr.Status, fr.Err = StatusSkipped, skip
+	case ctx.Err() != nil:
+		fr.Status, fr.Err = StatusSkipped, ctx.Err()
 	default:
+		fr.Status, fr.Err = StatusFailed, err
+	}
+	d.add(fr)
+
+	if fr.Status == StatusFailed && !d.keepGoing {
+		d.abort(err)
 	}
 }
+
+func (d *Downloader) abort(err error) {
+	d.abortOnce.Do(func() {
+		d.abortErr = err
+		d.cancel()
+	})
+}
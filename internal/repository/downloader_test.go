@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// peakTracker counts raw downloads in flight and remembers the most seen at
+// once.
+type peakTracker struct {
+	inFlight, peak, total atomic.Int64
+}
+
+func (p *peakTracker) serve(f *fakeGitHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := p.inFlight.Add(1)
+		defer p.inFlight.Add(-1)
+		p.total.Add(1)
+		for {
+			peak := p.peak.Load()
+			if n <= peak || p.peak.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+
+		// Long enough for every worker allowed to run to pile up here.
+		time.Sleep(20 * time.Millisecond)
+		f.serveContents(w, r, r.URL.Path[len("/api/v3/repos/"+testOwner+"/"+testRepo+"/contents/"):])
+	}
+}
+
+func manyFiles(dirs []string, perDir int) map[string]string {
+	files := make(map[string]string)
+	for _, dir := range dirs {
+		for i := 0; i < perDir; i++ {
+			files[fmt.Sprintf("%s/file%02d.txt", dir, i)] = fmt.Sprintf("%s %d\n", dir, i)
+		}
+	}
+	return files
+}
+
+func TestDownloadCapsConcurrentFetches(t *testing.T) {
+	for _, listMode := range []ListMode{ListTrees, ListContents} {
+		t.Run(listMode.String(), func(t *testing.T) {
+			f := newFakeGitHub(t, manyFiles([]string{"data", "data/nested"}, 12))
+			var tracker peakTracker
+			f.raw = tracker.serve(f)
+
+			const jobs = 3
+			_, _, err := f.download(t, "data", Options{ListMode: listMode, Jobs: jobs, ListJobs: 2})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+
+			if got := tracker.total.Load(); got != 24 {
+				t.Errorf("fetched %d files, want 24", got)
+			}
+			if peak := tracker.peak.Load(); peak > jobs || peak < 2 {
+				t.Errorf("peak concurrent fetches = %d, want 2..%d", peak, jobs)
+			}
+		})
+	}
+}
+
+func TestDownloadBatchSharesFetchBudget(t *testing.T) {
+	f := newFakeGitHub(t, manyFiles([]string{"a", "b", "c"}, 8))
+	var tracker peakTracker
+	f.raw = tracker.serve(f)
+
+	var targets []Target
+	for _, dir := range []string{"a", "b", "c"} {
+		g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo + "/" + dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, Target{URL: g})
+	}
+
+	// Each target's downloader runs jobs file workers of its own; only the
+	// shared budget keeps the batch as a whole at jobs.
+	const jobs = 2
+	_, err := DownloadBatch(context.Background(), targets, Options{Quiet: true, OutputDir: t.TempDir(), Jobs: jobs})
+	if err != nil {
+		t.Fatalf("DownloadBatch: %v", err)
+	}
+
+	if got := tracker.total.Load(); got != 24 {
+		t.Errorf("fetched %d files, want 24", got)
+	}
+	if peak := tracker.peak.Load(); peak > jobs {
+		t.Errorf("peak concurrent fetches = %d, want at most %d", peak, jobs)
+	}
+}
+
+func TestDownloadKeepsExecutableBit(t *testing.T) {
+	for _, opts := range []Options{
+		{ListMode: ListTrees},
+		{ListMode: ListContents},
+		{ListMode: ListTrees, Mode: ModeArchive},
+		{ListMode: ListContents, Mode: ModeArchive},
+	} {
+		t.Run(opts.ListMode.String()+"/"+opts.Mode.String(), func(t *testing.T) {
+			f := newFakeGitHub(t, map[string]string{
+				"bin/run.sh":     "#!/bin/sh\n",
+				"bin/readme.txt": "run run.sh\n",
+			})
+
+			_, dir, err := f.download(t, "bin", opts)
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+
+			for name, want := range map[string]os.FileMode{"run.sh": 0755, "readme.txt": 0644} {
+				info, err := os.Stat(filepath.Join(dir, "bin", name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := info.Mode().Perm(); got != want {
+					t.Errorf("%s mode = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadResolvesPathPastListingCap(t *testing.T) {
+	files := manyFiles([]string{"pkg"}, 5)
+	files["pkg/zz/doc.txt"] = "last\n"
+	for _, tt := range []struct {
+		basePath, local, want string
+	}{
+		{"pkg/zz", "zz/doc.txt", "last\n"},
+		{"pkg/file04.txt", "file04.txt", "pkg 4\n"},
+	} {
+		t.Run(tt.basePath, func(t *testing.T) {
+			f := newFakeGitHub(t, files)
+			// Both paths sort after the entries a capped listing of pkg
+			// would return.
+			f.listLimit = 2
+
+			_, dir, err := f.download(t, tt.basePath, Options{ListMode: ListTrees})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if got := readFile(t, dir, tt.local); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.local, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSymlinkRefusesChainedEscape(t *testing.T) {
+	d := &Downloader{outputDir: t.TempDir()}
+	dir := filepath.Join(d.outputDir, "repo", "d")
+
+	// Each target looks local on its own: x leads to the output root, and
+	// a only climbs one level from there.
+	if err := d.writeSymlink(filepath.Join(dir, "x"), "../.."); err != nil {
+		t.Fatalf("x -> ../..: %v", err)
+	}
+	if err := d.writeSymlink(filepath.Join(dir, "ok"), "x/repo"); err != nil {
+		t.Fatalf("ok -> x/repo: %v", err)
+	}
+
+	err := d.writeSymlink(filepath.Join(dir, "a"), "x/../evil")
+	var skip *skipError
+	if !errors.As(err, &skip) {
+		t.Fatalf("a -> x/../evil: err = %v, want a skip", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "a")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a was created: %v", err)
+	}
+}
+
+func TestWriteRefusesSymlinkedParent(t *testing.T) {
+	d := &Downloader{outputDir: t.TempDir()}
+	outside := t.TempDir()
+	dir := filepath.Join(d.outputDir, "repo", "d")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Left by an earlier run, or by anything else.
+	if err := os.Symlink(outside, filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.writeFile(filepath.Join(dir, "a", "evil"), strings.NewReader("x"), 0644); err == nil {
+		t.Error("writeFile through a symlinked directory succeeded")
+	}
+	if err := d.writeFile(filepath.Join(dir, "a", "sub", "evil"), strings.NewReader("x"), 0644); err == nil {
+		t.Error("writeFile below a symlinked directory succeeded")
+	}
+	if err := d.writeSymlink(filepath.Join(dir, "a", "link"), "."); err == nil {
+		t.Error("writeSymlink through a symlinked directory succeeded")
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("wrote %d entries outside the output directory", len(entries))
+	}
+}
+
+// failingRaw fails raw downloads of the bad paths with a 500 and serves the
+// rest after delay, counting the ones started and finished.
+type failingRaw struct {
+	bad               map[string]bool
+	delay             time.Duration
+	started, finished atomic.Int64
+}
+
+func (fr *failingRaw) serve(f *fakeGitHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/"+testOwner+"/"+testRepo+"/contents/")
+		if fr.bad[p] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fr.started.Add(1)
+		defer fr.finished.Add(1)
+		time.Sleep(fr.delay)
+		f.serveContents(w, r, p)
+	}
+}
+
+func TestDownloadKeepGoingReportsEveryFailure(t *testing.T) {
+	files := manyFiles([]string{"a", "b"}, 10)
+	f := newFakeGitHub(t, files)
+	raw := &failingRaw{bad: map[string]bool{"a/file03.txt": true, "b/file07.txt": true}}
+	f.raw = raw.serve(f)
+
+	result, dir, err := f.download(t, "", Options{KeepGoing: true, Jobs: 4})
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("err = %v, want ErrIncomplete", err)
+	}
+
+	var failed []string
+	for _, fr := range result.Failed() {
+		failed = append(failed, fr.Path)
+	}
+	slices.Sort(failed)
+	if want := []string{"a/file03.txt", "b/file07.txt"}; !slices.Equal(failed, want) {
+		t.Errorf("failed = %v, want %v", failed, want)
+	}
+
+	for name, content := range files {
+		if raw.bad[name] {
+			continue
+		}
+		if got := readFile(t, dir, filepath.Join(testRepo, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestDownloadAbortsOnFirstFailure(t *testing.T) {
+	files := manyFiles([]string{"a"}, 30)
+	f := newFakeGitHub(t, files)
+	raw := &failingRaw{bad: map[string]bool{"a/file05.txt": true}, delay: 50 * time.Millisecond}
+	f.raw = raw.serve(f)
+
+	result, dir, err := f.download(t, "", Options{Jobs: 4})
+	if err == nil || !strings.Contains(err.Error(), "a/file05.txt") {
+		t.Fatalf("err = %v, want the failure of a/file05.txt", err)
+	}
+
+	// Download only returns once its workers have, so nothing is left
+	// writing behind it.
+	written := func() int {
+		entries, _ := os.ReadDir(filepath.Join(dir, testRepo, "a"))
+		return len(entries)
+	}
+	before := written()
+	time.Sleep(200 * time.Millisecond)
+	if after := written(); after != before {
+		t.Errorf("%d files written after Download returned", after-before)
+	}
+
+	if got := len(result.Downloaded()); got != before {
+		t.Errorf("%d downloads recorded, but %d files on disk", got, before)
+	}
+	if started := raw.started.Load(); started >= int64(len(files)-1) {
+		t.Errorf("%d downloads started, want the run cut short", started)
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Filter narrows a download to some of the files under the base path.
+// Patterns are matched against paths relative to the downloaded directory.
+// "*", "?" and "[...]" work within one path segment, "**" spans any number of
+// segments, and a pattern without a slash matches the file or directory name
+// at any depth.
+type Filter struct {
+	Include []string
+	Exclude []string
+	// MaxSize skips files GitHub reports as larger than this many bytes.
+	// Zero means no limit.
+	MaxSize int64
+	// Patterns, when set, must also select a file for it to be fetched.
+	Patterns *Patterns
+
+	// prefix is prepended to paths so that a fetched submodule is filtered
+	// as part of its parent's tree.
+	prefix string
+}
+
+// Validate reports the first malformed pattern.
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// includes reports whether the file at rel passes the include and exclude
+// patterns. A pattern matching one of its directories counts as matching the
+// file. Size is checked separately so oversized files can be reported.
+func (f Filter) includes(rel string) bool {
+	rel = path.Join(f.prefix, rel)
+	if f.Patterns != nil && !f.Patterns.includes(rel) {
+		return false
+	}
+
+	included := len(f.Include) == 0
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if matchAny(f.Exclude, p) {
+			return false
+		}
+		included = included || matchAny(f.Include, p)
+	}
+	return included
+}
+
+// descends reports whether the directory at rel can contain anything the
+// filter would keep, so that excluded subtrees are never listed.
+func (f Filter) descends(rel string) bool {
+	rel = path.Join(f.prefix, rel)
+	if rel == "" || rel == "." {
+		return true
+	}
+	for p := rel; p != "."; p = path.Dir(p) {
+		if matchAny(f.Exclude, p) {
+			return false
+		}
+	}
+	if f.Patterns != nil && !f.Patterns.descends(rel) {
+		return false
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+
+	dir := strings.Split(rel, "/")
+	for _, pattern := range f.Include {
+		if !strings.Contains(pattern, "/") || matchPrefix(strings.Split(pattern, "/"), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// relPath is what the filter matches a repository path against: the path
+// below the downloaded directory, or the file name when a single file was
+// requested.
+func (d *Downloader) relPath(repoPath string) string {
+	switch {
+	case d.basePath == "":
+		return repoPath
+	case repoPath == d.basePath:
+		return path.Base(repoPath)
+	default:
+		return strings.TrimPrefix(repoPath, d.basePath+"/")
+	}
+}
+
+// wanted applies the filter to a listed entry. Files over the size limit are
+// recorded as skipped so they appear in the summary; excluded ones are simply
+// left out, as if they had never been listed.
+func (d *Downloader) wanted(ctx context.Context, file remoteFile) bool {
+	rel := d.relPath(file.Path)
+	if file.Type == "submodule" {
+		return d.filter.descends(rel)
+	}
+	if !d.filter.includes(rel) {
+		return false
+	}
+
+	if file.Type == "file" && d.filter.tooLarge(file.Size) {
+		d.record(ctx, file, "", &skipError{reason: fmt.Sprintf("%d bytes is over the size limit", file.Size)})
+		return false
+	}
+	return true
+}
+
+func (f Filter) tooLarge(size int) bool {
+	return f.MaxSize > 0 && int64(size) > f.MaxSize
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a pattern. A pattern
+// without a slash is tried against the last segment only.
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// matchPrefix reports whether the directory segments, or some path below
+// them, could match pattern. A pattern the directory uses up completely
+// matches the directory itself, and so everything in it.
+func matchPrefix(pattern, dir []string) bool {
+	for len(dir) > 0 && len(pattern) > 0 {
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], dir[0]); !ok {
+			return false
+		}
+		pattern, dir = pattern[1:], dir[1:]
+	}
+	return true
+}
+
+// ParseSize parses a byte count such as 512, 200K, 10MB or 1.5GiB. Suffixes
+// are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	number := strings.TrimRightFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	unit := strings.ToUpper(strings.TrimSpace(s[len(number):]))
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+
+	multipliers := map[string]float64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+	multiplier, ok := multipliers[unit]
+	n, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 500K, 10MB or 1G)", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// FormatSize renders a byte count the way ParseSize reads it back.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value, suffix := float64(n), ""
+	for _, s := range []string{"K", "M", "G"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f %sB", value, suffix)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterIncludesAndDescends(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		path             string
+		includes         bool
+		descends         bool
+	}{
+		// A literal directory path keeps the directory and all below it.
+		{"literal directory", []string{"docs/api"}, nil, "docs/api", true, true},
+		{"inside literal directory", []string{"docs/api"}, nil, "docs/api/v1/x.md", true, true},
+		{"parent of literal directory", []string{"docs/api"}, nil, "docs", false, true},
+		{"sibling of literal directory", []string{"docs/api"}, nil, "docs/guide", false, false},
+		{"trailing slash", []string{"docs/api/"}, nil, "docs/api", true, true},
+		{"glob segment", []string{"docs/*"}, nil, "docs/api", true, true},
+		{"name at any depth", []string{"api"}, nil, "src/api", true, true},
+
+		// ** spans any number of directories, including none.
+		{"double star prefix", []string{"**/testdata"}, nil, "a/b/testdata", true, true},
+		{"double star prefix at top", []string{"**/testdata"}, nil, "testdata", true, true},
+		{"double star leads anywhere", []string{"**/testdata"}, nil, "a/b", false, true},
+		{"double star suffix", []string{"src/**"}, nil, "src/a/b", true, true},
+		{"double star suffix elsewhere", []string{"src/**"}, nil, "lib", false, false},
+		{"double star middle", []string{"src/**/*.proto"}, nil, "src/a", false, true},
+
+		// Exclude wins over include, at the directory or any parent.
+		{"excluded directory", nil, []string{"vendor"}, "vendor", false, false},
+		{"inside excluded directory", nil, []string{"vendor"}, "vendor/lib/x.go", false, false},
+		{"excluded literal path", []string{"docs"}, []string{"docs/internal"}, "docs/internal", false, false},
+		{"excluded path spares sibling", []string{"docs"}, []string{"docs/internal"}, "docs/public", true, true},
+		{"excluded double star", nil, []string{"**/node_modules"}, "web/node_modules/x", false, false},
+		{"no patterns", nil, nil, "any/where", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filter{Include: tt.include, Exclude: tt.exclude}
+			patterns := "include " + strings.Join(tt.include, " ") + ", exclude " + strings.Join(tt.exclude, " ")
+			if got := f.includes(tt.path); got != tt.includes {
+				t.Errorf("%s: includes(%s) = %v, want %v", patterns, tt.path, got, tt.includes)
+			}
+			if got := f.descends(tt.path); got != tt.descends {
+				t.Errorf("%s: descends(%s) = %v, want %v", patterns, tt.path, got, tt.descends)
+			}
+		})
+	}
+}
+
+func TestDownloadIncludesLiteralDirectory(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{
+		"docs/api/index.md": "api\n",
+		"docs/guide.md":     "guide\n",
+	})
+
+	_, dir, err := f.download(t, "", Options{ListMode: ListContents, Filter: Filter{Include: []string{"docs/api"}}})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got := readFile(t, dir, "demo/docs/api/index.md"); got != "api\n" {
+		t.Errorf("docs/api/index.md = %q", got)
+	}
+}
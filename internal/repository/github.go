@@ -11,71 +11,135 @@ import (
 )
 
 type GitHubURL struct {
+	Host       Host
 	Owner      string
 	Repository string
-	Path       string // path within the repository
-	Branch     string // branch/ref
+	Path       string  // path within the repository
+	Branch     string  // branch/ref
+	RefKind    RefKind // what Branch is, once resolved
 	RawURL     string
+
+	// refPath is everything after /tree/ or /blob/ until ResolveRef works
+	// out where the ref ends and the path begins.
+	refPath     string
+	refOverride string
 }
 
+var (
+	ownerRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-_])*[a-zA-Z0-9]$|^[a-zA-Z0-9]$`)
+	repoRegex  = regexp.MustCompile(`^[a-zA-Z0-9._\-]+$`)
+
+	// shorthandRegex matches owner/repo[@ref][:path].
+	shorthandRegex = regexp.MustCompile(`^([^/@:]+)/([^/@:]+)(?:@([^:]+))?(?::(.*))?$`)
+)
+
+// ParseGitHubURL accepts:
+//
+//	https://github.com/owner/repo[.git]
+//	https://github.com/owner/repo/tree|blob/<ref>/<path>
+//	https://github.com/owner/repo/commit/<sha>
+//	https://github.com/owner/repo/releases/tag/<tag>
+//	https://raw.githubusercontent.com/owner/repo/<ref>/<path>
+//	git@github.com:owner/repo.git and ssh://git@github.com/owner/repo.git
+//	owner/repo[@ref][:path]
+//
+// The same forms work for Enterprise Server hosts listed in
+// PGIT_ENTERPRISE_HOSTS, whose raw links are https://<host>/raw/owner/repo/...
+// or https://<host>/owner/repo/raw/<ref>/<path>.
+//
+// A ref taken from a /tree/, /blob/ or raw URL is only a guess until
+// ResolveRef is called, since ref names may contain slashes.
 func ParseGitHubURL(urlStr string) (*GitHubURL, error) {
 	if urlStr == "" {
 		return nil, fmt.Errorf("URL cannot be empty")
 	}
+	rawURL := urlStr
+
+	if m := shorthandRegex.FindStringSubmatch(urlStr); m != nil && !strings.Contains(urlStr, "://") && !strings.HasPrefix(urlStr, "git@") {
+		githubURL, err := newGitHubURL(m[1], m[2], urlStr)
+		if err != nil {
+			return nil, err
+		}
+		githubURL.Path = strings.Trim(m[4], "/")
+		if m[3] != "" {
+			githubURL.Branch = m[3]
+			githubURL.refOverride = m[3]
+		}
+		return githubURL, nil
+	}
+
+	// scp-like SSH remotes have no scheme; rewrite them into a URL.
+	if rest, ok := strings.CutPrefix(urlStr, "git@"); ok && !strings.Contains(urlStr, "://") {
+		host, repoPath, ok := strings.Cut(rest, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid SSH remote %q (expected git@github.com:owner/repo.git)", urlStr)
+		}
+		urlStr = "ssh://git@" + host + "/" + repoPath
+	}
 
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL format: %w", err)
 	}
 
-	if parsedURL.Host != "github.com" && parsedURL.Host != "www.github.com" {
-		return nil, fmt.Errorf("URL must be from github.com")
+	host, err := lookupHost(parsedURL.Host)
+	if err != nil {
+		return nil, err
 	}
+	raw := parsedURL.Hostname() == "raw.githubusercontent.com"
 
 	if parsedURL.Scheme == "" {
 		return nil, fmt.Errorf("URL must include scheme (https://)")
 	}
 
-	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
+	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" && (parsedURL.Scheme != "ssh" || raw) {
 		return nil, fmt.Errorf("URL scheme must be http or https")
 	}
 
 	pathParts := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	if host.IsEnterprise() && pathParts[0] == "raw" {
+		raw, pathParts = true, pathParts[1:]
+	}
 	if len(pathParts) < 2 {
 		return nil, fmt.Errorf("GitHub URL must include owner and repository (e.g., https://github.com/owner/repo)")
 	}
 
-	if pathParts[0] == "" || pathParts[1] == "" {
-		return nil, fmt.Errorf("GitHub URL must include valid owner and repository names")
-	}
-
-	nameRegex := regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-_])*[a-zA-Z0-9]$|^[a-zA-Z0-9]$`)
-	if !nameRegex.MatchString(pathParts[0]) {
-		return nil, fmt.Errorf("invalid GitHub owner name: %s", pathParts[0])
-	}
-	if !nameRegex.MatchString(pathParts[1]) {
-		return nil, fmt.Errorf("invalid GitHub repository name: %s", pathParts[1])
+	githubURL, err := newGitHubURL(pathParts[0], pathParts[1], rawURL)
+	if err != nil {
+		return nil, err
 	}
+	githubURL.Host = host
 
-	githubURL := &GitHubURL{
-		Owner:      pathParts[0],
-		Repository: pathParts[1],
-		RawURL:     urlStr,
+	if raw {
+		// /owner/repo/<ref>/path/to/file, where the ref may also be spelled
+		// refs/heads/<branch> or refs/tags/<tag>.
+		if len(pathParts) < 4 {
+			return nil, fmt.Errorf("raw URL must include a ref and a file path")
+		}
+		refPath := strings.Join(pathParts[2:], "/")
+		refPath = strings.TrimPrefix(refPath, "refs/heads/")
+		refPath = strings.TrimPrefix(refPath, "refs/tags/")
+		githubURL.setRefPath(refPath)
+		return githubURL, nil
 	}
 
 	if len(pathParts) > 2 {
 		// Handle different GitHub URL patterns:
 		// /owner/repo/tree/branch/path/to/file
 		// /owner/repo/blob/branch/path/to/file
+		// /owner/repo/raw/branch/path/to/file
+		// /owner/repo/commit/sha
+		// /owner/repo/releases/tag/tag
 		// /owner/repo/path/to/file (direct path)
 
-		if len(pathParts) > 3 && (pathParts[2] == "tree" || pathParts[2] == "blob") {
-			// URL has branch/ref specified
-			githubURL.Branch = pathParts[3]
-			if len(pathParts) > 4 {
-				githubURL.Path = strings.Join(pathParts[4:], "/")
-			}
-		} else {
+		switch {
+		case len(pathParts) > 3 && (pathParts[2] == "tree" || pathParts[2] == "blob" || pathParts[2] == "raw"):
+			githubURL.setRefPath(strings.Join(pathParts[3:], "/"))
+		case len(pathParts) == 4 && pathParts[2] == "commit":
+			githubURL.Branch, githubURL.RefKind = pathParts[3], RefCommit
+		case len(pathParts) > 4 && pathParts[2] == "releases" && pathParts[3] == "tag":
+			githubURL.Branch, githubURL.RefKind = strings.Join(pathParts[4:], "/"), RefTag
+		default:
 			// Direct path without branch specification
 			githubURL.Path = strings.Join(pathParts[2:], "/")
 		}
@@ -84,30 +148,82 @@ func ParseGitHubURL(urlStr string) (*GitHubURL, error) {
 	return githubURL, nil
 }
 
+func newGitHubURL(owner, repo, rawURL string) (*GitHubURL, error) {
+	repo = strings.TrimSuffix(repo, ".git")
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("GitHub URL must include valid owner and repository names")
+	}
+
+	if !ownerRegex.MatchString(owner) {
+		return nil, fmt.Errorf("invalid GitHub owner name: %s", owner)
+	}
+	if !repoRegex.MatchString(repo) || repo == "." || repo == ".." {
+		return nil, fmt.Errorf("invalid GitHub repository name: %s", repo)
+	}
+
+	return &GitHubURL{
+		Host:       DotCom,
+		Owner:      owner,
+		Repository: repo,
+		RawURL:     rawURL,
+	}, nil
+}
+
+// setRefPath records the ref-and-path tail of a URL. Until ResolveRef is
+// called, it assumes the common case of a ref without slashes.
+func (g *GitHubURL) setRefPath(refPath string) {
+	g.Branch, g.Path, _ = strings.Cut(refPath, "/")
+	g.refPath = refPath
+}
+
 func (g *GitHubURL) String() string {
+	if g.Host.IsEnterprise() {
+		return fmt.Sprintf("%s/%s/%s", g.Host.Name, g.Owner, g.Repository)
+	}
 	return fmt.Sprintf("%s/%s", g.Owner, g.Repository)
 }
 
 func (g *GitHubURL) GetAPIURL() string {
-	return fmt.Sprintf("https://api.github.com/repos/%s/%s", g.Owner, g.Repository)
+	return fmt.Sprintf("%srepos/%s/%s", g.Host.APIURL(), g.Owner, g.Repository)
 }
 
 func (g *GitHubURL) GetCloneURL() string {
-	return fmt.Sprintf("https://github.com/%s/%s.git", g.Owner, g.Repository)
+	return fmt.Sprintf("%s%s/%s.git", g.host().BaseURL, g.Owner, g.Repository)
+}
+
+func (g *GitHubURL) host() Host {
+	if g.Host.IsEnterprise() {
+		return g.Host
+	}
+	return DotCom
+}
+
+// client returns an API client for the URL's host with default options.
+func (g *GitHubURL) client() *GitHubClient {
+	opts := DefaultClientOptions()
+	opts.Host = g.Host
+	return NewGitHubClientWithOptions(opts)
+}
+
+func (g *GitHubURL) Download(ctx context.Context) (*Result, error) {
+	return g.DownloadWithOptions(ctx, DefaultOptions())
 }
 
-func (g *GitHubURL) Download(ctx context.Context) error {
-	return g.DownloadWithOptions(ctx, false)
+// DownloadWithOptions resolves the URL's ref if that has not been done yet
+// and downloads the path it names.
+func (g *GitHubURL) DownloadWithOptions(ctx context.Context, opts Options) (*Result, error) {
+	opts.Client.Host = g.Host
+	client := NewGitHubClientWithOptions(opts.Client)
+	return g.downloadTarget(ctx, client, opts)
 }
 
-func (g *GitHubURL) DownloadWithOptions(ctx context.Context, quiet bool) error {
-	client := NewGitHubClient()
-	downloader := NewDownloaderWithOptions(client, g.Owner, g.Repository, g.Path, g.Branch, quiet)
+func (g *GitHubURL) downloadWith(ctx context.Context, client *GitHubClient, opts Options) (*Result, error) {
+	downloader := NewDownloaderWithOptions(client, g.Owner, g.Repository, g.Path, g.Branch, opts)
 	return downloader.Download(ctx)
 }
 
 func (g *GitHubURL) IsPrivate(ctx context.Context) (bool, error) {
-	client := NewGitHubClient()
+	client := g.client()
 	repo, err := client.GetRepository(ctx, g.Owner, g.Repository)
 	if err != nil {
 		// assume it might be private or doesn't exist
@@ -118,11 +234,11 @@ func (g *GitHubURL) IsPrivate(ctx context.Context) (bool, error) {
 }
 
 func (g *GitHubURL) GetRateLimit(ctx context.Context) (*github.RateLimits, error) {
-	client := NewGitHubClient()
+	client := g.client()
 	return client.GetRateLimit(ctx)
 }
 
 func (g *GitHubURL) GetAuthenticatedUser(ctx context.Context) (*github.User, error) {
-	client := NewGitHubClient()
+	client := g.client()
 	return client.GetAuthenticatedUser(ctx)
 }
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseGitHubURL(t *testing.T) {
+	t.Setenv(EnterpriseHostsEnv, "ghe.example.com,http://127.0.0.1:8080")
+
+	tests := []struct {
+		url    string
+		host   string
+		owner  string
+		repo   string
+		branch string
+		path   string
+		kind   RefKind
+		// refPending is set when the ref still has to be resolved.
+		refPending bool
+		wantErr    bool
+	}{
+		{url: "https://github.com/owner/repo", host: "github.com", owner: "owner", repo: "repo"},
+		{url: "https://github.com/owner/repo.git", host: "github.com", owner: "owner", repo: "repo"},
+		{url: "https://github.com/owner/repo/tree/main/docs/api", host: "github.com", owner: "owner", repo: "repo", branch: "main", path: "docs/api", refPending: true},
+		{url: "https://github.com/owner/repo/blob/v1.0/README.md", host: "github.com", owner: "owner", repo: "repo", branch: "v1.0", path: "README.md", refPending: true},
+		{url: "https://github.com/owner/repo/commit/abc1234", host: "github.com", owner: "owner", repo: "repo", branch: "abc1234", kind: RefCommit},
+		{url: "https://github.com/owner/repo/releases/tag/v2.1.0", host: "github.com", owner: "owner", repo: "repo", branch: "v2.1.0", kind: RefTag},
+		{url: "https://github.com/owner/repo/src/main.go", host: "github.com", owner: "owner", repo: "repo", path: "src/main.go"},
+		{url: "https://raw.githubusercontent.com/owner/repo/refs/heads/main/go.mod", host: "github.com", owner: "owner", repo: "repo", branch: "main", path: "go.mod", refPending: true},
+
+		// Shorthand.
+		{url: "owner/repo", host: "github.com", owner: "owner", repo: "repo"},
+		{url: "owner/repo:docs", host: "github.com", owner: "owner", repo: "repo", path: "docs"},
+		{url: "owner/repo@v1.2.0", host: "github.com", owner: "owner", repo: "repo", branch: "v1.2.0", refPending: true},
+		{url: "owner/repo@feature/x:src/pkg/", host: "github.com", owner: "owner", repo: "repo", branch: "feature/x", path: "src/pkg", refPending: true},
+
+		// SSH remotes.
+		{url: "git@github.com:owner/repo.git", host: "github.com", owner: "owner", repo: "repo"},
+		{url: "ssh://git@github.com/owner/repo.git", host: "github.com", owner: "owner", repo: "repo"},
+		{url: "git@ghe.example.com:team/tools.git", host: "ghe.example.com", owner: "team", repo: "tools"},
+
+		// Enterprise Server.
+		{url: "https://ghe.example.com/team/tools/tree/main/cmd", host: "ghe.example.com", owner: "team", repo: "tools", branch: "main", path: "cmd", refPending: true},
+		{url: "https://ghe.example.com/raw/team/tools/main/Makefile", host: "ghe.example.com", owner: "team", repo: "tools", branch: "main", path: "Makefile", refPending: true},
+		{url: "http://127.0.0.1:8080/team/tools", host: "127.0.0.1:8080", owner: "team", repo: "tools"},
+
+		// Errors.
+		{url: "", wantErr: true},
+		{url: "https://gitlab.com/owner/repo", wantErr: true},
+		{url: "https://github.com/owner", wantErr: true},
+		{url: "ftp://github.com/owner/repo", wantErr: true},
+		{url: "git@github.com/owner/repo", wantErr: true},
+		{url: "https://github.com/-owner/repo", wantErr: true},
+		{url: "https://raw.githubusercontent.com/owner/repo/main", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := ParseGitHubURL(tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseGitHubURL(%q) = %+v, want an error", tt.url, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGitHubURL(%q): %v", tt.url, err)
+			}
+
+			if got.Host.Name != tt.host || got.Owner != tt.owner || got.Repository != tt.repo {
+				t.Errorf("repository = %s %s/%s, want %s %s/%s", got.Host.Name, got.Owner, got.Repository, tt.host, tt.owner, tt.repo)
+			}
+			if got.Branch != tt.branch || got.Path != tt.path || got.RefKind != tt.kind {
+				t.Errorf("ref = %q (%q) path %q, want %q (%q) path %q", got.Branch, got.RefKind, got.Path, tt.branch, tt.kind, tt.path)
+			}
+			if pending := got.refPath != "" || got.refOverride != ""; pending != tt.refPending {
+				t.Errorf("ref pending = %v, want %v", pending, tt.refPending)
+			}
+		})
+	}
+}
+
+func TestCountAPICallsCoversRefResolution(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{"docs/a.txt": "alpha\n"})
+	g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo + "/tree/main/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int64
+	ctx := CountAPICalls(context.Background(), &calls)
+	if private, err := g.IsPrivate(ctx); err != nil || !private {
+		t.Errorf("IsPrivate = %v, %v", private, err)
+	}
+	if err := g.ResolveRef(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if g.Branch != "main" || g.Path != "docs" {
+		t.Errorf("resolved to %q path %q", g.Branch, g.Path)
+	}
+
+	// One repository lookup, then one matching-refs call each for branches
+	// and tags.
+	if got := calls.Load(); got != 3 {
+		t.Errorf("counted %d API calls, want 3", got)
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// EnterpriseHostsEnv lists the GitHub Enterprise Server instances pgit accepts
+// URLs for, comma separated. Each entry is a hostname, for which https is
+// assumed, or a base URL such as http://127.0.0.1:8080.
+const EnterpriseHostsEnv = "PGIT_ENTERPRISE_HOSTS"
+
+// Host is a GitHub instance: github.com itself or an Enterprise Server.
+type Host struct {
+	// Name is the host as it appears in URLs, including any port.
+	Name string
+	// BaseURL is the web root, e.g. https://ghe.example.com/.
+	BaseURL string
+}
+
+var DotCom = Host{Name: "github.com", BaseURL: "https://github.com/"}
+
+func (h Host) IsEnterprise() bool {
+	return h.Name != "" && h.Name != DotCom.Name
+}
+
+// APIURL is the REST API root: api.github.com, or /api/v3/ on Enterprise
+// Server.
+func (h Host) APIURL() string {
+	if !h.IsEnterprise() {
+		return "https://api.github.com/"
+	}
+	return h.BaseURL + "api/v3/"
+}
+
+// ParseHost turns a hostname or base URL into a Host. An empty string,
+// github.com and its aliases map to DotCom.
+func ParseHost(s string) (Host, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DotCom, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return Host{}, fmt.Errorf("invalid GitHub host %q", s)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return Host{}, fmt.Errorf("GitHub host %q must use http or https", s)
+	}
+
+	switch u.Hostname() {
+	case "github.com", "www.github.com", "api.github.com", "raw.githubusercontent.com":
+		return DotCom, nil
+	}
+
+	return Host{Name: u.Host, BaseURL: u.Scheme + "://" + u.Host + "/"}, nil
+}
+
+// EnterpriseHosts returns the hosts configured in PGIT_ENTERPRISE_HOSTS.
+func EnterpriseHosts() ([]Host, error) {
+	var hosts []Host
+	for _, entry := range strings.Split(os.Getenv(EnterpriseHostsEnv), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		host, err := ParseHost(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnterpriseHostsEnv, err)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// lookupHost finds the instance a URL host belongs to. Enterprise hosts are
+// only recognised when configured, so a typo in a github.com URL is not
+// mistaken for a private server.
+func lookupHost(name string) (Host, error) {
+	switch name {
+	case "github.com", "www.github.com", "raw.githubusercontent.com":
+		return DotCom, nil
+	}
+
+	hosts, err := EnterpriseHosts()
+	if err != nil {
+		return Host{}, err
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(host.Name, name) {
+			return host, nil
+		}
+	}
+
+	return Host{}, fmt.Errorf("URL must be from github.com or a host listed in %s", EnterpriseHostsEnv)
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLookupHost(t *testing.T) {
+	t.Setenv(EnterpriseHostsEnv, "ghe.example.com, http://127.0.0.1:8080")
+
+	tests := []struct {
+		name    string
+		want    Host
+		wantErr bool
+	}{
+		{name: "github.com", want: DotCom},
+		{name: "raw.githubusercontent.com", want: DotCom},
+		{name: "ghe.example.com", want: Host{Name: "ghe.example.com", BaseURL: "https://ghe.example.com/"}},
+		{name: "GHE.example.com", want: Host{Name: "ghe.example.com", BaseURL: "https://ghe.example.com/"}},
+		{name: "127.0.0.1:8080", want: Host{Name: "127.0.0.1:8080", BaseURL: "http://127.0.0.1:8080/"}},
+		{name: "gitlab.com", wantErr: true},
+		{name: "ghe.example.org", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := lookupHost(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("lookupHost(%q) = %+v, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("lookupHost(%q) = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestRawURL(t *testing.T) {
+	enterprise := Host{Name: "ghe.example.com", BaseURL: "https://ghe.example.com/"}
+	for _, tt := range []struct {
+		host Host
+		want string
+	}{
+		{DotCom, "https://raw.githubusercontent.com/octo/demo/" + testCommit + "/docs/a%20b.md"},
+		{enterprise, "https://ghe.example.com/api/v3/repos/octo/demo/contents/docs/a%20b.md?ref=" + testCommit},
+	} {
+		d := &Downloader{client: &GitHubClient{host: tt.host}, owner: testOwner, repo: testRepo, commitSHA: testCommit}
+		if got := d.rawURL("docs/a b.md"); got != tt.want {
+			t.Errorf("rawURL on %s = %s, want %s", tt.host.Name, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadFromEnterpriseServer(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{"docs/a.txt": "alpha\n", "docs/b.txt": "beta\n"})
+
+	var mu sync.Mutex
+	var raw []*http.Request
+	f.raw = func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		raw = append(raw, r)
+		mu.Unlock()
+		f.serveContents(w, r, strings.TrimPrefix(r.URL.Path, "/api/v3/repos/"+testOwner+"/"+testRepo+"/contents/"))
+	}
+
+	g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Host != f.host {
+		t.Fatalf("host = %+v, want %+v", g.Host, f.host)
+	}
+	if want := f.URL + "/api/v3/repos/octo/demo"; g.GetAPIURL() != want {
+		t.Errorf("API URL = %s, want %s", g.GetAPIURL(), want)
+	}
+
+	dir := t.TempDir()
+	if _, err := g.DownloadWithOptions(context.Background(), Options{Quiet: true, OutputDir: dir}); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got := readFile(t, dir, "docs/b.txt"); got != "beta\n" {
+		t.Errorf("docs/b.txt = %q", got)
+	}
+
+	if len(raw) != 2 {
+		t.Fatalf("%d raw downloads, want 2", len(raw))
+	}
+	for _, r := range raw {
+		if got := r.URL.Query().Get("ref"); got != testCommit {
+			t.Errorf("%s fetched at ref %q, want the resolved commit", r.URL.Path, got)
+		}
+		if got := r.Header.Get("Authorization"); got != "token test-token" {
+			t.Errorf("%s sent Authorization %q", r.URL.Path, got)
+		}
+	}
+}
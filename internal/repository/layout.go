@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// skipError marks a file that was deliberately not written. record counts it
+// as skipped rather than failed.
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// getExactPath maps a repository path to where it is written locally. The
+// default layout keeps the downloaded directory's own name (or the repository
+// name for a whole repo) as the top-level folder; strip-components and flat
+// reshape that, and the result is always confined to the output directory.
+func (d *Downloader) getExactPath(base, path string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return "", fmt.Errorf("refusing to write unsafe repository path %q", path)
+	}
+
+	var localPath string
+	if base == "" {
+		localPath = filepath.Join(d.repo, path)
+	} else {
+		relPath, err := filepath.Rel(base, path)
+		if err != nil {
+			return "", err
+		}
+		localPath = filepath.Join(filepath.Base(base), relPath)
+	}
+
+	switch {
+	case d.flat:
+		localPath = filepath.Base(path)
+	case d.stripComponents > 0:
+		parts := strings.Split(filepath.ToSlash(localPath), "/")
+		if len(parts) <= d.stripComponents {
+			return "", &skipError{reason: fmt.Sprintf("fewer than %d path components to strip", d.stripComponents+1)}
+		}
+		localPath = filepath.Join(parts[d.stripComponents:]...)
+	}
+
+	localPath, err := d.confine(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	if d.flat {
+		if err := d.claim(localPath, path); err != nil {
+			return "", err
+		}
+	}
+
+	return localPath, nil
+}
+
+// confine joins relPath onto the output directory, refusing anything that
+// would land outside it. Repository paths come from the server, so a hostile
+// tree or archive entry such as "../../.bashrc" must never be honoured.
+func (d *Downloader) confine(relPath string) (string, error) {
+	if !filepath.IsLocal(relPath) {
+		return "", fmt.Errorf("refusing to write %q outside the output directory", relPath)
+	}
+
+	return filepath.Join(d.outputDir, relPath), nil
+}
+
+// claim records which repository path owns localPath so that a flat layout
+// reports name collisions instead of silently overwriting one file with another.
+// Fetched submodules share the top-level downloader's claims.
+func (d *Downloader) claim(localPath, path string) error {
+	for d.parent != nil {
+		d = d.parent
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if owner, ok := d.claimed[localPath]; ok && owner != path {
+		return fmt.Errorf("%s and %s both map to %s in flat layout", owner, path, localPath)
+	}
+	d.claimed[localPath] = path
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newLayout(strip int, flat bool) *Downloader {
+	return &Downloader{repo: "demo", outputDir: "out", stripComponents: strip, flat: flat, claimed: make(map[string]string)}
+}
+
+func TestGetExactPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		strip int
+		flat  bool
+		base  string
+		path  string
+		want  string
+	}{
+		{"whole repository", 0, false, "", "docs/a.md", "demo/docs/a.md"},
+		{"directory keeps its name", 0, false, "docs/api", "docs/api/v1/a.md", "api/v1/a.md"},
+		{"single file", 0, false, "docs/a.md", "docs/a.md", "a.md"},
+		{"strip one", 1, false, "docs", "docs/api/a.md", "api/a.md"},
+		{"strip to the file", 2, false, "docs", "docs/api/a.md", "a.md"},
+		{"flat", 0, true, "docs", "docs/api/v1/a.md", "a.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newLayout(tt.strip, tt.flat).getExactPath(tt.base, tt.path)
+			if err != nil {
+				t.Fatalf("getExactPath(%q, %q): %v", tt.base, tt.path, err)
+			}
+			if want := filepath.Join("out", filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("getExactPath(%q, %q) = %s, want %s", tt.base, tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestGetExactPathRejectsTraversal(t *testing.T) {
+	for _, path := range []string{
+		"../evil",
+		"docs/../../evil",
+		"a/../../b",
+		"/etc/passwd",
+		"..",
+		"",
+	} {
+		for _, d := range []*Downloader{newLayout(0, false), newLayout(1, false), newLayout(0, true)} {
+			if got, err := d.getExactPath("", path); err == nil {
+				t.Errorf("getExactPath(%q) with strip %d, flat %v = %s, want an error", path, d.stripComponents, d.flat, got)
+			}
+		}
+	}
+}
+
+func TestConfineRejectsEscape(t *testing.T) {
+	d := newLayout(0, false)
+	for _, rel := range []string{"../x", "a/../../x", "/abs/x", ""} {
+		if got, err := d.confine(rel); err == nil {
+			t.Errorf("confine(%q) = %s, want an error", rel, got)
+		}
+	}
+}
+
+func TestGetExactPathStripPastDepth(t *testing.T) {
+	// "api/a.md" below docs has two components, so stripping two leaves
+	// nothing to write.
+	_, err := newLayout(2, false).getExactPath("docs/api", "docs/api/a.md")
+	var skip *skipError
+	if !errors.As(err, &skip) {
+		t.Fatalf("err = %v, want a skip", err)
+	}
+}
+
+func TestGetExactPathFlatCollision(t *testing.T) {
+	d := newLayout(0, true)
+	first, err := d.getExactPath("", "a/readme.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file may be mapped again, on a retry or in a second pass.
+	if again, err := d.getExactPath("", "a/readme.md"); err != nil || again != first {
+		t.Errorf("second mapping of a/readme.md = %s, %v", again, err)
+	}
+
+	_, err = d.getExactPath("", "b/readme.md")
+	if err == nil || !strings.Contains(err.Error(), "both map to") {
+		t.Errorf("b/readme.md: err = %v, want a collision", err)
+	}
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+var errCodeExpired = errors.New("the code expired before it was entered")
+
+// DeviceFlow signs in through GitHub's OAuth device authorization flow: the
+// user enters a short code in the browser while pgit polls for the token.
+// The OAuth app must have device flow enabled.
+type DeviceFlow struct {
+	Host     Host
+	ClientID string
+	Scopes   []string
+
+	client *http.Client
+	sleep  func(context.Context, time.Duration) error
+}
+
+func NewDeviceFlow(host Host, clientID string, scopes []string) *DeviceFlow {
+	if !host.IsEnterprise() {
+		host = DotCom
+	}
+	return &DeviceFlow{
+		Host:     host,
+		ClientID: clientID,
+		Scopes:   scopes,
+		client:   &http.Client{Transport: newTransport(DefaultRequestTimeout)},
+		sleep:    sleepContext,
+	}
+}
+
+// DeviceCode is what the user is shown, and what Poll needs afterwards.
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// oauthResponse covers both endpoints: GitHub answers errors with status 200
+// and an error field.
+type oauthResponse struct {
+	DeviceCode
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (r *oauthResponse) err() error {
+	if r.ErrorDescription != "" {
+		return fmt.Errorf("%s (%s)", r.ErrorDescription, r.Error)
+	}
+	return errors.New(r.Error)
+}
+
+// RequestCode starts the flow.
+func (f *DeviceFlow) RequestCode(ctx context.Context) (*DeviceCode, error) {
+	resp, err := f.post(ctx, "login/device/code", url.Values{
+		"client_id": {f.ClientID},
+		"scope":     {strings.Join(f.Scopes, " ")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request a device code: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("failed to request a device code: %w", resp.err())
+	}
+	if resp.DeviceCode.DeviceCode == "" || resp.UserCode == "" {
+		return nil, fmt.Errorf("failed to request a device code: incomplete response from %s", f.Host.Name)
+	}
+	return &resp.DeviceCode, nil
+}
+
+// Poll waits for the user to enter the code and returns the access token.
+func (f *DeviceFlow) Poll(ctx context.Context, code *DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	if code.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	for {
+		if err := f.sleep(ctx, interval); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return "", errCodeExpired
+			}
+			return "", err
+		}
+
+		resp, err := f.post(ctx, "login/oauth/access_token", url.Values{
+			"client_id":   {f.ClientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		})
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", errCodeExpired
+			}
+			return "", fmt.Errorf("failed to poll for the access token: %w", err)
+		}
+
+		switch resp.Error {
+		case "":
+			if resp.AccessToken == "" {
+				return "", fmt.Errorf("no access token in the response from %s", f.Host.Name)
+			}
+			return resp.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			// GitHub says how long to wait from now on, which is at least
+			// five seconds more than before.
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			} else {
+				interval += defaultPollInterval
+			}
+		case "expired_token":
+			return "", errCodeExpired
+		case "access_denied":
+			return "", fmt.Errorf("authorization was denied")
+		default:
+			return "", resp.err()
+		}
+	}
+}
+
+func (f *DeviceFlow) post(ctx context.Context, endpoint string, form url.Values) (*oauthResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.Host.BaseURL+endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+
+	var out oauthResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("unexpected response from %s: %w", req.URL, err)
+	}
+	return &out, nil
+}
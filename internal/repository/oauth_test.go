@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAuthServer answers the device flow endpoints, replying to successive
+// token polls from a script.
+type fakeAuthServer struct {
+	*httptest.Server
+	polls   []map[string]any
+	polled  int
+	request http.Header
+}
+
+func newFakeAuthServer(t *testing.T, polls ...map[string]any) *fakeAuthServer {
+	t.Helper()
+
+	s := &fakeAuthServer{polls: polls}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("client_id") != "test-client" {
+			http.Error(w, "bad client", http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/login/device/code":
+			s.request = r.Header
+			json.NewEncoder(w).Encode(map[string]any{
+				"device_code":      "device-123",
+				"user_code":        "ABCD-1234",
+				"verification_uri": s.URL + "/login/device",
+				"expires_in":       900,
+				"interval":         5,
+				"scope":            r.Form.Get("scope"),
+			})
+		case "/login/oauth/access_token":
+			if r.Form.Get("device_code") != "device-123" {
+				http.Error(w, "bad device code", http.StatusBadRequest)
+				return
+			}
+			reply := s.polls[min(s.polled, len(s.polls)-1)]
+			s.polled++
+			json.NewEncoder(w).Encode(reply)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// deviceFlow returns a flow against the server that records its waits
+// instead of sleeping.
+func (s *fakeAuthServer) deviceFlow(waits *[]time.Duration) *DeviceFlow {
+	flow := NewDeviceFlow(Host{Name: strings.TrimPrefix(s.URL, "http://"), BaseURL: s.URL + "/"}, "test-client", []string{"repo", "read:org"})
+	flow.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return flow
+}
+
+func TestDeviceFlow(t *testing.T) {
+	pending := map[string]any{"error": "authorization_pending"}
+	granted := map[string]any{"access_token": "gho_granted", "token_type": "bearer", "scope": "repo"}
+
+	tests := []struct {
+		name      string
+		polls     []map[string]any
+		want      string
+		wantErr   string
+		wantWaits []time.Duration
+	}{
+		{
+			name:      "pending then granted",
+			polls:     []map[string]any{pending, pending, granted},
+			want:      "gho_granted",
+			wantWaits: []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:      "slow down with interval",
+			polls:     []map[string]any{{"error": "slow_down", "interval": 10}, pending, granted},
+			want:      "gho_granted",
+			wantWaits: []time.Duration{5 * time.Second, 10 * time.Second, 10 * time.Second},
+		},
+		{
+			name:      "slow down without interval",
+			polls:     []map[string]any{{"error": "slow_down"}, {"error": "slow_down"}, granted},
+			want:      "gho_granted",
+			wantWaits: []time.Duration{5 * time.Second, 10 * time.Second, 15 * time.Second},
+		},
+		{
+			name:      "expired",
+			polls:     []map[string]any{pending, {"error": "expired_token"}},
+			wantErr:   "expired",
+			wantWaits: []time.Duration{5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:      "denied",
+			polls:     []map[string]any{{"error": "access_denied"}},
+			wantErr:   "denied",
+			wantWaits: []time.Duration{5 * time.Second},
+		},
+		{
+			name:      "unknown error",
+			polls:     []map[string]any{{"error": "incorrect_client_credentials", "error_description": "The client_id is not valid."}},
+			wantErr:   "The client_id is not valid.",
+			wantWaits: []time.Duration{5 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeAuthServer(t, tt.polls...)
+			var waits []time.Duration
+			flow := server.deviceFlow(&waits)
+
+			code, err := flow.RequestCode(context.Background())
+			if err != nil {
+				t.Fatalf("RequestCode: %v", err)
+			}
+			if code.UserCode != "ABCD-1234" || code.VerificationURI != server.URL+"/login/device" {
+				t.Errorf("code = %+v", code)
+			}
+			if got := server.request.Get("Accept"); got != "application/json" {
+				t.Errorf("Accept = %q, want application/json", got)
+			}
+
+			got, err := flow.Poll(context.Background(), code)
+			switch {
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Poll = %q, %v; want an error mentioning %q", got, err, tt.wantErr)
+			case tt.wantErr == "" && (err != nil || got != tt.want):
+				t.Errorf("Poll = %q, %v; want %q", got, err, tt.want)
+			}
+			if !slices.Equal(waits, tt.wantWaits) {
+				t.Errorf("waited %v, want %v", waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestDeviceFlowRequestCodeError(t *testing.T) {
+	server := newFakeAuthServer(t)
+	flow := NewDeviceFlow(Host{Name: "example", BaseURL: server.URL + "/"}, "unknown-client", nil)
+	if _, err := flow.RequestCode(context.Background()); err == nil {
+		t.Error("RequestCode succeeded for an unknown client")
+	}
+}
+
+func TestDeviceFlowCodeExpiresWhilePolling(t *testing.T) {
+	server := newFakeAuthServer(t, map[string]any{"error": "authorization_pending"})
+	flow := NewDeviceFlow(Host{Name: "example", BaseURL: server.URL + "/"}, "test-client", nil)
+	flow.sleep = func(ctx context.Context, d time.Duration) error {
+		return sleepContext(ctx, time.Millisecond)
+	}
+
+	code := &DeviceCode{DeviceCode: "device-123", ExpiresIn: 1, Interval: 1}
+	_, err := flow.Poll(context.Background(), code)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Poll = %v, want the code to expire", err)
+	}
+}
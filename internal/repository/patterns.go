@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Patterns selects files the way git sparse-checkout does, from a file in
+// gitignore syntax where a match means "fetch this" rather than "ignore this":
+//
+//	/*            files at the top level
+//	!/*/          but no directories
+//	/docs/        except docs, in full
+//	!*.png        and never PNGs
+//
+// The last matching pattern decides, a leading ! negates, a leading or inner
+// slash anchors the pattern to the downloaded directory, and a trailing slash
+// matches directories only. As in git, once a directory is excluded nothing
+// inside it can be brought back.
+//
+// In cone mode the file instead lists directories: every file under them is
+// fetched, together with the files directly inside each of their parents and
+// at the top level.
+type Patterns struct {
+	rules []patternRule
+	cone  bool
+	dirs  []string // cone mode only
+}
+
+type patternRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ReadPatterns loads a pattern file, such as a .pgit file checked into the
+// repository that is downloading into.
+func ReadPatterns(filename string, cone bool) (*Patterns, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pattern file: %w", err)
+	}
+	defer file.Close()
+
+	p := &Patterns{cone: cone}
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if err := p.add(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pattern file: %w", err)
+	}
+
+	return p, nil
+}
+
+func (p *Patterns) add(line string) error {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	if p.cone {
+		return p.addConeDir(line)
+	}
+
+	var rule patternRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate, line = true, line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // \! and \# escape a literal first character
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	rule.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return fmt.Errorf("empty pattern")
+	}
+
+	rule.segments = strings.Split(line, "/")
+	for _, segment := range rule.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", line, err)
+		}
+	}
+
+	p.rules = append(p.rules, rule)
+	return nil
+}
+
+// addConeDir accepts a plain directory name, or one of the lines git itself
+// writes for cone mode: "/*", "!/*/", "/dir/" and "!/dir/*/". The last form
+// follows a parent of a deeper directory and takes it back out of the list,
+// since only the files directly inside it are wanted.
+func (p *Patterns) addConeDir(line string) error {
+	if line == "/*" || line == "!/*/" {
+		return nil
+	}
+	if parent, ok := strings.CutSuffix(strings.TrimPrefix(line, "!"), "/*/"); ok && line[0] == '!' {
+		parent = strings.Trim(parent, "/")
+		p.dirs = slices.DeleteFunc(p.dirs, func(dir string) bool { return dir == parent })
+		return nil
+	}
+
+	dir := strings.Trim(line, "/")
+	if strings.ContainsAny(line, "!*?[\\") {
+		return fmt.Errorf("cone mode takes directories, not patterns: %q", line)
+	}
+	if dir != "" {
+		p.dirs = append(p.dirs, dir)
+	}
+	return nil
+}
+
+// match returns whether the last rule matching rel includes it, and whether
+// any rule matched at all.
+func (p *Patterns) match(rel string, isDir bool) (included, matched bool) {
+	segments := strings.Split(rel, "/")
+	for i := len(p.rules) - 1; i >= 0; i-- {
+		rule := p.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		var ok bool
+		if rule.anchored {
+			ok = matchSegments(rule.segments, segments)
+		} else {
+			ok, _ = path.Match(rule.segments[0], segments[len(segments)-1])
+		}
+		if ok {
+			return !rule.negate, true
+		}
+	}
+	return false, false
+}
+
+// includes reports whether the file at rel is selected.
+func (p *Patterns) includes(rel string) bool {
+	if p.cone {
+		for _, dir := range p.dirs {
+			if within(rel, dir) {
+				return true
+			}
+		}
+		parent := path.Dir(rel)
+		return parent == "." || p.coneAncestor(parent)
+	}
+
+	// Walk down from the top: an excluded directory hides everything in
+	// it, an included one selects it unless something closer says no.
+	segments := strings.Split(rel, "/")
+	selected := false
+	for i := range segments {
+		included, matched := p.match(strings.Join(segments[:i+1], "/"), i < len(segments)-1)
+		switch {
+		case !matched:
+		case !included:
+			return false
+		default:
+			selected = true
+		}
+	}
+	return selected
+}
+
+// descends reports whether the directory at rel may hold selected files.
+func (p *Patterns) descends(rel string) bool {
+	if p.cone {
+		for _, dir := range p.dirs {
+			if within(rel, dir) {
+				return true
+			}
+		}
+		return p.coneAncestor(rel)
+	}
+
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		if included, matched := p.match(strings.Join(segments[:i+1], "/"), true); matched && !included {
+			return false
+		}
+	}
+	return true
+}
+
+// coneAncestor reports whether dir is a parent of one of the cone directories.
+func (p *Patterns) coneAncestor(dir string) bool {
+	for _, coneDir := range p.dirs {
+		if strings.HasPrefix(coneDir, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func within(rel, dir string) bool {
+	return rel == dir || strings.HasPrefix(rel, dir+"/")
+}
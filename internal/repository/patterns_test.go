@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func newPatterns(t *testing.T, cone bool, lines ...string) *Patterns {
+	t.Helper()
+
+	p := &Patterns{cone: cone}
+	for _, line := range lines {
+		if err := p.add(line); err != nil {
+			t.Fatalf("add(%q): %v", line, err)
+		}
+	}
+	return p
+}
+
+func TestPatternsIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		// Negation: the last matching pattern wins.
+		{"negated extension", []string{"*.go", "!*_test.go"}, "pkg/util_test.go", false},
+		{"negation leaves others", []string{"*.go", "!*_test.go"}, "pkg/util.go", true},
+		{"no pattern matches", []string{"*.go", "!*_test.go"}, "README.md", false},
+		{"later pattern re-includes", []string{"*.md", "!*.md", "README.md"}, "README.md", true},
+		{"negated subdirectory", []string{"docs/", "!docs/internal/"}, "docs/internal/notes.md", false},
+		{"negated subdirectory sibling", []string{"docs/", "!docs/internal/"}, "docs/guide.md", true},
+		{"excluded directory cannot be re-included", []string{"*", "!vendor/", "vendor/keep.txt"}, "vendor/keep.txt", false},
+		{"escaped bang", []string{`\!important.txt`}, "!important.txt", true},
+
+		// Anchoring: a leading or inner slash ties the pattern to the top.
+		{"anchored at top", []string{"/build"}, "build/out.txt", true},
+		{"anchored not nested", []string{"/build"}, "src/build/out.txt", false},
+		{"unanchored nested", []string{"build"}, "src/build/out.txt", true},
+		{"inner slash anchors", []string{"docs/*.md"}, "docs/guide.md", true},
+		{"inner slash not nested", []string{"docs/*.md"}, "site/docs/guide.md", false},
+		{"double star", []string{"/src/**/*.proto"}, "src/a/b/c.proto", true},
+		{"double star zero segments", []string{"/src/**/*.proto"}, "src/c.proto", true},
+
+		// Directory-only: a trailing slash never matches a file.
+		{"directory only matches directory", []string{"logs/"}, "logs/today.txt", true},
+		{"directory only skips file", []string{"logs/"}, "logs", false},
+		{"directory only nested", []string{"logs/"}, "app/logs/today.txt", true},
+		{"anchored directory only", []string{"/logs/"}, "app/logs/today.txt", false},
+
+		// The sparse-checkout example from the Patterns documentation.
+		{"sparse top-level file", []string{"/*", "!/*/", "/docs/", "!*.png"}, "Makefile", true},
+		{"sparse other directory", []string{"/*", "!/*/", "/docs/", "!*.png"}, "src/main.go", false},
+		{"sparse included directory", []string{"/*", "!/*/", "/docs/", "!*.png"}, "docs/api/index.md", true},
+		{"sparse negated file", []string{"/*", "!/*/", "/docs/", "!*.png"}, "docs/logo.png", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPatterns(t, false, tt.patterns...)
+			if got := p.includes(tt.path); got != tt.want {
+				t.Errorf("patterns %q include %s = %v, want %v", strings.Join(tt.patterns, " "), tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternsDescends(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		dir      string
+		want     bool
+	}{
+		{"unmatched directory", []string{"*.go"}, "pkg", true},
+		{"negated directory", []string{"*", "!vendor/"}, "vendor", false},
+		{"inside negated directory", []string{"*", "!vendor/"}, "vendor/lib", false},
+		{"negated anchored directory", []string{"*", "!/third_party/"}, "src/third_party", true},
+		{"negated pattern also matches directories", []string{"*", "!*.d"}, "conf.d", false},
+		{"negated pattern spares other directories", []string{"*", "!*.d"}, "conf", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPatterns(t, false, tt.patterns...)
+			if got := p.descends(tt.dir); got != tt.want {
+				t.Errorf("patterns %q descend into %s = %v, want %v", strings.Join(tt.patterns, " "), tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternsCone(t *testing.T) {
+	// What git sparse-checkout set --cone a/b writes.
+	p := newPatterns(t, true, "/*", "!/*/", "/a/", "!/a/*/", "/a/b/")
+
+	for rel, want := range map[string]bool{
+		"README.md":  true,
+		"a/top.txt":  true,
+		"a/b/c/d.go": true,
+		"a/other/x":  false,
+		"z/file.txt": false,
+	} {
+		if got := p.includes(rel); got != want {
+			t.Errorf("includes(%s) = %v, want %v", rel, got, want)
+		}
+	}
+
+	for rel, want := range map[string]bool{"a": true, "a/b/c": true, "a/other": false, "z": false} {
+		if got := p.descends(rel); got != want {
+			t.Errorf("descends(%s) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestPatternsRejectInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		cone bool
+	}{
+		{"!", false},
+		{"/", false},
+		{"[", false},
+		{"src/*.go", true},
+		{"!docs", true},
+	} {
+		p := &Patterns{cone: tt.cone}
+		if err := p.add(tt.line); err == nil {
+			t.Errorf("add(%q) with cone=%v succeeded, want an error", tt.line, tt.cone)
+		}
+	}
+}
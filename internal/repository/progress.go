@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth = 24
+	redrawInterval   = 200 * time.Millisecond
+	logInterval      = 5 * time.Second
+)
+
+// progress tracks how far a run has got and reports it: a bar redrawn in
+// place when stderr is a terminal, or a log line every few seconds when it is
+// not. Like budget, one progress is shared by every Downloader in a run. A
+// nil *progress, as used with Quiet, tracks and prints nothing.
+type progress struct {
+	mu              sync.Mutex
+	downloadedCount int   // files finished, whatever the outcome
+	totalCount      int   // files queued so far; grows while listing
+	bytes           int64 // bytes written
+	totalBytes      int64 // listed size of the queued files
+	started         time.Time
+	drawn           bool
+
+	tty  bool
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newProgress() *progress {
+	return &progress{tty: isTerminal(os.Stderr), done: make(chan struct{})}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+func (p *progress) start() {
+	if p == nil {
+		return
+	}
+	p.started = time.Now()
+
+	interval := logInterval
+	if p.tty {
+		interval = redrawInterval
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends reporting, leaving the final state of the bar on screen.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty && p.totalCount > 0 {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s\n", p.line())
+	}
+}
+
+// expect adds a queued file to the totals.
+func (p *progress) expect(file remoteFile) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.totalCount++
+	p.totalBytes += int64(file.Size)
+}
+
+// finish counts one expected file as done.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.downloadedCount++
+}
+
+// reader counts the bytes read through r.
+func (p *progress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &countingReader{r: r, p: p}
+}
+
+type countingReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.p.mu.Lock()
+	c.p.bytes += int64(n)
+	c.p.mu.Unlock()
+	return n, err
+}
+
+// printf prints a log line. On a terminal the bar is cleared first and
+// redrawn below the line, so the two never mix.
+func (p *progress) printf(format string, args ...any) {
+	if p == nil || !p.tty {
+		fmt.Printf(format, args...)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	fmt.Printf(format, args...)
+	if p.drawn {
+		fmt.Fprint(os.Stderr, p.line())
+	}
+}
+
+func (p *progress) report() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.totalCount == 0 {
+		return
+	}
+	if p.tty {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s", p.line())
+		p.drawn = true
+	} else {
+		// Kept off stdout, which may be piped into something expecting only
+		// the log of files.
+		fmt.Fprintf(os.Stderr, "Progress: %s\n", p.line())
+	}
+}
+
+// line renders the current state. The ETA extrapolates from files rather
+// than bytes, since files skipped as up to date never transfer theirs.
+func (p *progress) line() string {
+	elapsed := time.Since(p.started)
+	rate := float64(p.bytes) / elapsed.Seconds()
+
+	eta := "--"
+	if p.downloadedCount > 0 && p.downloadedCount < p.totalCount {
+		remaining := elapsed * time.Duration(p.totalCount-p.downloadedCount) / time.Duration(p.downloadedCount)
+		eta = remaining.Round(time.Second).String()
+	} else if p.downloadedCount >= p.totalCount {
+		eta = "0s"
+	}
+
+	size := FormatSize(p.bytes)
+	if p.totalBytes > 0 {
+		size += "/" + FormatSize(p.totalBytes)
+	}
+
+	status := fmt.Sprintf("%d/%d files  %s  %s/s  ETA %s",
+		p.downloadedCount, p.totalCount, size, FormatSize(int64(rate)), eta)
+	if !p.tty {
+		return status
+	}
+
+	filled := progressBarWidth * p.downloadedCount / p.totalCount
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled) + "] " + status
+}
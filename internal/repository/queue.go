@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+// workQueue is an unbounded FIFO shared by a fixed set of workers. Pushing
+// never blocks, so a worker that discovers more work (a directory listing
+// yielding subdirectories) can't deadlock against its own pool.
+type workQueue[T any] struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []T
+	closed bool
+}
+
+func newWorkQueue[T any]() *workQueue[T] {
+	q := &workQueue[T]{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *workQueue[T]) push(item T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items = append(q.items, item)
+	q.cond.Signal()
+}
+
+// pop blocks until an item is available or the queue is closed and drained.
+func (q *workQueue[T]) pop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+
+	var item T
+	if len(q.items) == 0 {
+		return item, false
+	}
+
+	item = q.items[0]
+	q.items = q.items[1:]
+	return item, true
+}
+
+func (q *workQueue[T]) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// semaphore bounds work shared between Downloaders, such as the targets of a
+// batch or a fetched submodule and its parent, which each run their own
+// worker pools.
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+// budget is the shared concurrency limit: fetch slots for file downloads and
+// list slots for API listing calls.
+type budget struct {
+	fetch semaphore
+	list  semaphore
+}
+
+func newBudget(jobs, listJobs int) *budget {
+	return &budget{fetch: make(semaphore, jobs), list: make(semaphore, listJobs)}
+}
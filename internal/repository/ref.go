@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RefKind says what a GitHubURL's ref turned out to be once resolved.
+type RefKind string
+
+const (
+	// RefDefault means no ref was given and the default branch is used.
+	RefDefault RefKind = ""
+	RefBranch  RefKind = "branch"
+	RefTag     RefKind = "tag"
+	RefCommit  RefKind = "commit"
+)
+
+var (
+	fullSHAPattern  = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	shortSHAPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// ResolveRef splits the part of a /tree/ or /blob/ URL after the marker into
+// ref and path. Branch and tag names may contain slashes, so the split can
+// only be made by asking GitHub which refs exist.
+func (g *GitHubURL) ResolveRef(ctx context.Context) error {
+	return g.resolveRef(ctx, g.client())
+}
+
+// SetRef overrides the ref named in the URL. A /tree/ or /blob/ URL is still
+// resolved first, since its own ref has to be found to know where the path
+// starts.
+func (g *GitHubURL) SetRef(ref string) {
+	g.refOverride = ref
+}
+
+// resolveRef settles Branch, RefKind and Path. It does nothing once the URL
+// has been resolved.
+func (g *GitHubURL) resolveRef(ctx context.Context, client *GitHubClient) error {
+	if g.refPath != "" {
+		ref, kind, err := g.matchRef(ctx, client, g.refPath)
+		if err != nil {
+			return err
+		}
+		g.Branch, g.RefKind = ref, kind
+		g.Path = strings.TrimPrefix(strings.TrimPrefix(g.refPath, ref), "/")
+		g.refPath = ""
+	}
+
+	if g.refOverride != "" {
+		ref, kind, err := g.matchRef(ctx, client, g.refOverride)
+		if err != nil {
+			return err
+		}
+		if ref != g.refOverride {
+			return fmt.Errorf("no branch, tag or commit of %s is named %q", g.String(), g.refOverride)
+		}
+		g.Branch, g.RefKind = ref, kind
+		g.refOverride = ""
+	}
+
+	return nil
+}
+
+// matchRef finds the longest branch or tag name that is a whole-segment prefix
+// of s; failing that, a leading commit SHA. Branches win over tags of the same
+// name, as they do for git itself with an unqualified name.
+func (g *GitHubURL) matchRef(ctx context.Context, client *GitHubClient, s string) (string, RefKind, error) {
+	first, _, _ := strings.Cut(s, "/")
+	if fullSHAPattern.MatchString(first) {
+		return first, RefCommit, nil
+	}
+
+	best, kind := "", RefDefault
+	for _, candidate := range []struct {
+		prefix string
+		kind   RefKind
+	}{
+		{"heads/", RefBranch},
+		{"tags/", RefTag},
+	} {
+		refs, err := client.ListMatchingRefs(ctx, g.Owner, g.Repository, candidate.prefix+first)
+		if err != nil {
+			return "", RefDefault, fmt.Errorf("failed to list refs of %s: %w", g.String(), err)
+		}
+
+		for _, ref := range refs {
+			name := strings.TrimPrefix(ref, "refs/"+candidate.prefix)
+			if (s == name || strings.HasPrefix(s, name+"/")) && len(name) > len(best) {
+				best, kind = name, candidate.kind
+			}
+		}
+	}
+
+	switch {
+	case best != "":
+		return best, kind, nil
+	case shortSHAPattern.MatchString(first):
+		return first, RefCommit, nil
+	default:
+		return "", RefDefault, fmt.Errorf("no branch, tag or commit of %s matches %q", g.String(), s)
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolveRef(t *testing.T) {
+	shortSHA := testCommit[:7]
+	tests := []struct {
+		name     string
+		refs     []string
+		refPath  string
+		wantRef  string
+		wantKind RefKind
+		wantPath string
+	}{
+		{
+			"longest branch with slashes",
+			[]string{"refs/heads/main", "refs/heads/feature", "refs/heads/feature/new-api"},
+			"feature/new-api/src", "feature/new-api", RefBranch, "src",
+		},
+		{
+			"shorter branch when the longer does not fit",
+			[]string{"refs/heads/feature", "refs/heads/feature/new-api"},
+			"feature/other/src", "feature", RefBranch, "other/src",
+		},
+		{
+			"segment prefix only",
+			[]string{"refs/heads/feat", "refs/heads/feature"},
+			"feature/src", "feature", RefBranch, "src",
+		},
+		{
+			"branch beats tag of the same name",
+			[]string{"refs/heads/release", "refs/tags/release"},
+			"release/docs", "release", RefBranch, "docs",
+		},
+		{
+			"longer tag beats shorter branch",
+			[]string{"refs/heads/v1", "refs/tags/v1/2"},
+			"v1/2/docs", "v1/2", RefTag, "docs",
+		},
+		{
+			"tag",
+			[]string{"refs/heads/main", "refs/tags/v1.0.0"},
+			"v1.0.0/docs", "v1.0.0", RefTag, "docs",
+		},
+		{
+			"full commit SHA",
+			[]string{"refs/heads/main"},
+			testCommit + "/docs", testCommit, RefCommit, "docs",
+		},
+		{
+			"short commit SHA",
+			[]string{"refs/heads/main"},
+			shortSHA + "/docs", shortSHA, RefCommit, "docs",
+		},
+		{
+			"ref without a path",
+			[]string{"refs/heads/feature/new-api"},
+			"feature/new-api", "feature/new-api", RefBranch, "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGitHub(t, nil)
+			f.refs = tt.refs
+			g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo + "/tree/" + tt.refPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := g.ResolveRef(context.Background()); err != nil {
+				t.Fatalf("ResolveRef: %v", err)
+			}
+			if g.Branch != tt.wantRef || g.RefKind != tt.wantKind || g.Path != tt.wantPath {
+				t.Errorf("resolved to %s %q path %q, want %s %q path %q", g.RefKind, g.Branch, g.Path, tt.wantKind, tt.wantRef, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestResolveRefUnknown(t *testing.T) {
+	f := newFakeGitHub(t, nil)
+	g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo + "/tree/nope/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.ResolveRef(context.Background()); err == nil || !strings.Contains(err.Error(), `matches "nope/docs"`) {
+		t.Errorf("ResolveRef = %v, want no match for nope/docs", err)
+	}
+}
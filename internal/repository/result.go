@@ -0,0 +1,81 @@
+package repository
+
+import "sync"
+
+type FileStatus string
+
+const (
+	StatusDownloaded FileStatus = "downloaded"
+	StatusFailed     FileStatus = "failed"
+	StatusSkipped    FileStatus = "skipped"
+	// StatusListed marks a file a dry run found but did not download.
+	StatusListed FileStatus = "listed"
+)
+
+// FileResult is the outcome for one repository path. Path may name a
+// directory when its listing failed or was never attempted.
+type FileResult struct {
+	Path      string
+	SHA       string // git blob SHA from the listing, when known
+	Type      string // file, symlink or submodule; empty for directories
+	Size      int64  // size from the listing, when known
+	LocalPath string
+	Status    FileStatus
+	Err       error
+}
+
+// Result collects the outcome of every path a Downloader touched. It is safe
+// for concurrent use by the download workers.
+type Result struct {
+	// Commit is the commit SHA the download was pinned to, if it was resolved.
+	Commit string
+	// APICalls counts the GitHub API requests the download made, retries
+	// and submodules included. Raw file downloads are not API calls.
+	APICalls int64
+
+	mu    sync.Mutex
+	files []FileResult
+}
+
+func (r *Result) add(fr FileResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files = append(r.files, fr)
+}
+
+func (r *Result) Files() []FileResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]FileResult(nil), r.files...)
+}
+
+func (r *Result) Downloaded() []FileResult {
+	return r.withStatus(StatusDownloaded)
+}
+
+func (r *Result) Failed() []FileResult {
+	return r.withStatus(StatusFailed)
+}
+
+func (r *Result) Skipped() []FileResult {
+	return r.withStatus(StatusSkipped)
+}
+
+func (r *Result) Listed() []FileResult {
+	return r.withStatus(StatusListed)
+}
+
+func (r *Result) withStatus(status FileStatus) []FileResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []FileResult
+	for _, fr := range r.files {
+		if fr.Status == status {
+			matched = append(matched, fr)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	DefaultRetries = 3
+
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+
+	// maxRateLimitWait is the longest pgit sleeps for a rate limit on its own.
+	// Anything longer is only waited out with --wait-for-rate-limit.
+	maxRateLimitWait = time.Minute
+)
+
+type retryPolicy struct {
+	retries          int
+	waitForRateLimit bool
+	// quiet stops rate-limit waits being reported on stderr.
+	quiet bool
+}
+
+func (p retryPolicy) logf(format string, args ...any) {
+	if !p.quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// retryTransport retries transient failures (network errors, 5xx) with
+// jittered exponential backoff and waits out rate limits using the
+// Retry-After and X-RateLimit-Reset headers GitHub sends with them.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy retryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+
+		delay, retry := t.policy.retryDelay(req, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (p retryPolicy) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if req.Context().Err() != nil {
+		return 0, false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return 0, false
+	}
+
+	if err != nil {
+		if attempt >= p.retries {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		wait, limited := rateLimitDelay(resp)
+		if !limited {
+			if resp.StatusCode == http.StatusForbidden || attempt >= p.retries {
+				return 0, false
+			}
+			return backoff(attempt), true
+		}
+
+		if !p.waitForRateLimit && (wait > maxRateLimitWait || attempt >= p.retries) {
+			return 0, false
+		}
+
+		p.logf("Rate limited by %s, waiting %s before retrying...\n", req.URL.Host, wait.Round(time.Second))
+		return wait, true
+
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if attempt >= p.retries {
+			return 0, false
+		}
+		if wait, ok := retryAfter(resp); ok {
+			return wait, true
+		}
+		return backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// rateLimitDelay reports how long GitHub asked us to back off for, and
+// whether the response was a rate limit at all rather than a plain 403.
+func rateLimitDelay(resp *http.Response) (time.Duration, bool) {
+	if wait, ok := retryAfter(resp); ok {
+		return wait, true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	// A second of slack so the retry lands after the window has rolled over.
+	wait := time.Until(time.Unix(reset, 0)) + time.Second
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		return max(time.Until(when), 0), true
+	}
+
+	return 0, false
+}
+
+func backoff(attempt int) time.Duration {
+	delay := maxBackoff
+	if attempt < 16 {
+		delay = min(baseBackoff<<attempt, maxBackoff)
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitForRateLimit covers the case the transport never sees: once go-github
+// has recorded an exhausted limit it fails calls locally until the reset time.
+// With --wait-for-rate-limit the call is repeated after the reset instead.
+func (gc *GitHubClient) waitForRateLimit(ctx context.Context, call func() error) error {
+	for {
+		err := call()
+
+		var rateLimitErr *github.RateLimitError
+		if !gc.retry.waitForRateLimit || !errors.As(err, &rateLimitErr) {
+			return err
+		}
+
+		wait := time.Until(rateLimitErr.Rate.Reset.Time) + time.Second
+		gc.retry.logf("GitHub API rate limit exceeded, waiting %s until it resets...\n", wait.Round(time.Second))
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
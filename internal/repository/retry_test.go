@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// retryServer answers every request with respond and counts them.
+func retryServer(t *testing.T, respond func(w http.ResponseWriter, n int64)) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, calls.Add(1))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func retryGet(ctx context.Context, t *testing.T, url string, retries int) (*http.Response, error) {
+	t.Helper()
+
+	client := &http.Client{Transport: &retryTransport{
+		base:   http.DefaultTransport,
+		policy: retryPolicy{retries: retries, quiet: true},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	server, calls := retryServer(t, func(w http.ResponseWriter, n int64) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	resp, err := retryGet(context.Background(), t, server.URL, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want the last 502", resp.StatusCode)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("%d requests, want 1 plus 2 retries", got)
+	}
+}
+
+func TestRetryRecoversFromServerError(t *testing.T) {
+	server, calls := retryServer(t, func(w http.ResponseWriter, n int64) {
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	resp, err := retryGet(context.Background(), t, server.URL, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	server, calls := retryServer(t, func(w http.ResponseWriter, n int64) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	})
+
+	start := time.Now()
+	resp, err := retryGet(context.Background(), t, server.URL, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestRetryHonoursRateLimitReset(t *testing.T) {
+	reset := time.Now().Truncate(time.Second).Add(time.Second)
+	server, calls := retryServer(t, func(w http.ResponseWriter, n int64) {
+		if n == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+
+	resp, err := retryGet(context.Background(), t, server.URL, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+	if now := time.Now(); now.Before(reset) {
+		t.Errorf("retried at %s, before the reset at %s", now.Format(time.StampMilli), reset.Format(time.StampMilli))
+	}
+}
+
+func TestRetryLeavesPlainForbidden(t *testing.T) {
+	server, calls := retryServer(t, func(w http.ResponseWriter, n int64) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	resp, err := retryGet(context.Background(), t, server.URL, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden || calls.Load() != 1 {
+		t.Errorf("status %d after %d requests, want 403 after 1", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	server, calls := retryServer(t, func(w http.ResponseWriter, n int64) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := retryGet(ctx, t, server.URL, 3)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %s, want soon after the cancellation", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestWaitForRateLimit(t *testing.T) {
+	limited := &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: time.Now()}}}
+
+	for _, wait := range []bool{false, true} {
+		gc := &GitHubClient{retry: retryPolicy{waitForRateLimit: wait, quiet: true}}
+		calls := 0
+		err := gc.waitForRateLimit(context.Background(), func() error {
+			calls++
+			if calls == 1 {
+				return limited
+			}
+			return nil
+		})
+
+		switch {
+		case wait && (err != nil || calls != 2):
+			t.Errorf("waiting: err = %v after %d calls, want success after 2", err, calls)
+		case !wait && (!errors.Is(err, limited) || calls != 1):
+			t.Errorf("not waiting: err = %v after %d calls, want the rate limit error after 1", err, calls)
+		}
+	}
+}
+
+func TestRateLimitNoticeRespectsQuiet(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.github.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}}
+
+	for _, quiet := range []bool{false, true} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr := os.Stderr
+		os.Stderr = w
+		retryPolicy{retries: 1, quiet: quiet}.retryDelay(req, resp, nil, 0)
+		os.Stderr = stderr
+		w.Close()
+
+		out, _ := io.ReadAll(r)
+		if got := strings.Contains(string(out), "Rate limited"); got == quiet {
+			t.Errorf("quiet %v: stderr = %q", quiet, out)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	testOwner  = "octo"
+	testRepo   = "demo"
+	testCommit = "0123456789abcdef0123456789abcdef01234567"
+)
+
+// fakeGitHub serves one repository at one commit the way an Enterprise Server
+// instance would, from the API root /api/v3/.
+type fakeGitHub struct {
+	*httptest.Server
+	host Host
+
+	files map[string]string
+	// refs are the full names of the branches and tags, all pointing at
+	// testCommit. The default is just refs/heads/main.
+	refs []string
+	// archive overrides what the tarball holds for a path; nil leaves the
+	// path out, as export-ignore does.
+	archive map[string]*string
+	// raw, if set, serves raw file downloads in place of the default.
+	raw http.HandlerFunc
+	// repoSizeKB is the repository size the API reports.
+	repoSizeKB int
+	// archives counts tarball downloads.
+	archives atomic.Int64
+	// listLimit, if set, truncates directory listings as the Contents API
+	// does at 1000 entries.
+	listLimit int
+}
+
+func newFakeGitHub(t *testing.T, files map[string]string) *fakeGitHub {
+	t.Helper()
+
+	f := &fakeGitHub{files: files, archive: map[string]*string{}, refs: []string{"refs/heads/main"}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	host, err := ParseHost(f.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.host = host
+
+	t.Setenv(EnterpriseHostsEnv, f.URL)
+	t.Setenv("GH_ENTERPRISE_TOKEN", "test-token")
+	t.Setenv("HOME", t.TempDir())
+	return f
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	repoPrefix := "/api/v3/repos/" + testOwner + "/" + testRepo + "/"
+	rest, ok := strings.CutPrefix(r.URL.Path, repoPrefix)
+	switch {
+	case r.URL.Path == "/archive.tar.gz":
+		f.serveArchive(w)
+	case r.URL.Path+"/" == repoPrefix:
+		json.NewEncoder(w).Encode(map[string]any{"name": testRepo, "private": true, "default_branch": "main", "size": f.repoSizeKB})
+	case !ok:
+		http.NotFound(w, r)
+	case strings.HasPrefix(rest, "commits/"):
+		w.Write([]byte(testCommit))
+	case strings.HasPrefix(rest, "git/matching-refs/"):
+		refs := []map[string]any{}
+		prefix := "refs/" + strings.TrimPrefix(rest, "git/matching-refs/")
+		for _, ref := range f.refs {
+			if strings.HasPrefix(ref, prefix) {
+				refs = append(refs, map[string]any{"ref": ref, "object": map[string]any{"sha": testCommit, "type": "commit"}})
+			}
+		}
+		json.NewEncoder(w).Encode(refs)
+	case strings.HasPrefix(rest, "git/trees/"):
+		f.serveTree(w, r, strings.TrimPrefix(rest, "git/trees/"))
+	case strings.HasPrefix(rest, "tarball/"):
+		http.Redirect(w, r, f.URL+"/archive.tar.gz", http.StatusFound)
+	case strings.HasPrefix(rest, "contents"):
+		p := strings.Trim(strings.TrimPrefix(rest, "contents"), "/")
+		if f.raw != nil && r.Header.Get("Accept") == "application/vnd.github.raw" {
+			f.raw(w, r)
+			return
+		}
+		f.serveContents(w, r, p)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fileMode makes scripts executable, so tests can check the mode is kept.
+func (f *fakeGitHub) fileMode(p string) string {
+	if strings.HasSuffix(p, ".sh") {
+		return executableMode
+	}
+	return "100644"
+}
+
+func treeSHA(dir string) string {
+	if dir == "" {
+		return testCommit
+	}
+	return blobSHA([]byte("tree " + dir))
+}
+
+// entries lists what lies directly or, if recursive, anywhere below dir.
+func (f *fakeGitHub) entries(dir string, recursive bool) []map[string]any {
+	seen := map[string]bool{}
+	var entries []map[string]any
+	for p, content := range f.files {
+		rel := p
+		if dir != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(p, dir+"/"); !ok {
+				continue
+			}
+		}
+
+		parts := strings.Split(rel, "/")
+		for i := 1; i < len(parts); i++ {
+			sub := strings.Join(parts[:i], "/")
+			if seen[sub] || (!recursive && i > 1) {
+				continue
+			}
+			seen[sub] = true
+			entries = append(entries, map[string]any{"path": sub, "type": "tree", "mode": "040000", "sha": treeSHA(path.Join(dir, sub))})
+		}
+		if !recursive && len(parts) > 1 {
+			continue
+		}
+		entries = append(entries, map[string]any{"path": rel, "type": "blob", "mode": f.fileMode(p), "sha": blobSHA([]byte(content)), "size": len(content)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i]["path"].(string) < entries[j]["path"].(string) })
+	return entries
+}
+
+func (f *fakeGitHub) serveTree(w http.ResponseWriter, r *http.Request, sha string) {
+	dir := ""
+	if commit, treePath, ok := strings.Cut(sha, ":"); ok && commit == testCommit {
+		dir, sha = treePath, treeSHA(treePath)
+	}
+	for p := range f.files {
+		for d := path.Dir(p); d != "."; d = path.Dir(d) {
+			if treeSHA(d) == sha {
+				dir = d
+			}
+		}
+	}
+	if dir == "" && sha != testCommit {
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"sha": sha, "tree": f.entries(dir, r.URL.Query().Get("recursive") != "")})
+}
+
+func (f *fakeGitHub) serveContents(w http.ResponseWriter, r *http.Request, p string) {
+	if content, ok := f.files[p]; ok {
+		if r.Header.Get("Accept") == "application/vnd.github.raw" {
+			w.Write([]byte(content))
+			return
+		}
+		json.NewEncoder(w).Encode(f.content(p, "file"))
+		return
+	}
+
+	var listing []map[string]any
+	for _, entry := range f.entries(p, false) {
+		full := path.Join(p, entry["path"].(string))
+		kind := "file"
+		if entry["type"] == "tree" {
+			kind = "dir"
+		}
+		listing = append(listing, f.content(full, kind))
+	}
+	if listing == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if f.listLimit > 0 && len(listing) > f.listLimit {
+		listing = listing[:f.listLimit]
+	}
+	json.NewEncoder(w).Encode(listing)
+}
+
+func (f *fakeGitHub) content(p, kind string) map[string]any {
+	content := map[string]any{"type": kind, "name": path.Base(p), "path": p, "sha": treeSHA(p)}
+	if kind == "file" {
+		content["sha"] = blobSHA([]byte(f.files[p]))
+		content["size"] = len(f.files[p])
+		content["download_url"] = f.URL + "/api/v3/repos/" + testOwner + "/" + testRepo + "/contents/" + p
+	}
+	return content
+}
+
+func (f *fakeGitHub) serveArchive(w http.ResponseWriter) {
+	f.archives.Add(1)
+	var paths []string
+	for p := range f.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, p := range paths {
+		content := f.files[p]
+		if override, ok := f.archive[p]; ok {
+			if override == nil {
+				continue
+			}
+			content = *override
+		}
+		mode := int64(0644)
+		if f.fileMode(p) == executableMode {
+			mode = 0755
+		}
+		tw.WriteHeader(&tar.Header{Name: testOwner + "-" + testRepo + "-0123456/" + p, Mode: mode, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	w.Write(buf.Bytes())
+}
+
+// download runs a quiet Downloader for basePath against the fake server,
+// writing under opts.OutputDir, or else a temporary directory, which is
+// returned with the result.
+func (f *fakeGitHub) download(t *testing.T, basePath string, opts Options) (*Result, string, error) {
+	t.Helper()
+
+	opts.Quiet = true
+	if opts.OutputDir == "" {
+		opts.OutputDir = t.TempDir()
+	}
+	opts.Client.Host = f.host
+	client := NewGitHubClientWithOptions(opts.Client)
+	result, err := NewDownloaderWithOptions(client, testOwner, testRepo, basePath, "main", opts).Download(context.Background())
+	return result, opts.OutputDir, err
+}
+
+// rawFetches records the paths raw downloads were served for.
+type rawFetches struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *rawFetches) serve(f *fakeGitHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		p := strings.TrimPrefix(req.URL.Path, "/api/v3/repos/"+testOwner+"/"+testRepo+"/contents/")
+		r.mu.Lock()
+		r.paths = append(r.paths, p)
+		r.mu.Unlock()
+		f.serveContents(w, req, p)
+	}
+}
+
+func (r *rawFetches) sorted() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	paths := append([]string(nil), r.paths...)
+	sort.Strings(paths)
+	return paths
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
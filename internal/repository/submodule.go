@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// maxSubmoduleDepth stops SubmodulesFetch from following submodules of
+// submodules forever, e.g. when two repositories include each other.
+const maxSubmoduleDepth = 5
+
+// SubmodulePolicy decides what happens to submodule entries in a listing.
+type SubmodulePolicy int
+
+const (
+	// SubmodulesSkip leaves submodules out, reporting them as skipped.
+	SubmodulesSkip SubmodulePolicy = iota
+	// SubmodulesRecord creates an empty directory for each submodule, as an
+	// uninitialised git checkout does, and reports its URL and commit.
+	SubmodulesRecord
+	// SubmodulesFetch downloads each submodule at its pinned commit.
+	SubmodulesFetch
+)
+
+func ParseSubmodulePolicy(s string) (SubmodulePolicy, error) {
+	switch s {
+	case "", "skip":
+		return SubmodulesSkip, nil
+	case "record":
+		return SubmodulesRecord, nil
+	case "fetch":
+		return SubmodulesFetch, nil
+	default:
+		return SubmodulesSkip, fmt.Errorf("unknown submodule policy %q (expected skip, record or fetch)", s)
+	}
+}
+
+func (p SubmodulePolicy) String() string {
+	switch p {
+	case SubmodulesRecord:
+		return "record"
+	case SubmodulesFetch:
+		return "fetch"
+	default:
+		return "skip"
+	}
+}
+
+func (d *Downloader) downloadSubmodule(ctx context.Context, file remoteFile, localPath string) error {
+	if d.submodules == SubmodulesSkip {
+		return &skipError{reason: "submodule not fetched (see --submodules)"}
+	}
+
+	subURL, err := d.submoduleURL(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	if d.submodules == SubmodulesRecord {
+		if err := os.MkdirAll(localPath, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for submodule %s: %w", file.Path, err)
+		}
+		return &skipError{reason: fmt.Sprintf("submodule %s at %s", subURL, file.SHA)}
+	}
+
+	if d.depth >= maxSubmoduleDepth {
+		return fmt.Errorf("submodule %s is nested more than %d levels deep", file.Path, maxSubmoduleDepth)
+	}
+
+	sub, err := ParseGitHubURL(subURL)
+	if err != nil {
+		return fmt.Errorf("cannot fetch submodule %s from %s: %w", file.Path, subURL, err)
+	}
+
+	d.logf("Fetching submodule: %s (%s@%s)\n", file.Path, sub.String(), file.SHA)
+
+	// The child writes into the submodule's own directory: its default
+	// layout would prefix the repository name, which strip-components drops.
+	opts := d.opts
+	opts.Timeout = 0 // ctx already carries the parent's deadline
+	opts.progress = d.progress
+	opts.Filter.prefix = path.Join(d.filter.prefix, d.relPath(file.Path))
+	if d.flat {
+		opts.OutputDir = d.outputDir
+	} else {
+		opts.OutputDir = localPath
+		opts.StripComponents = 1
+	}
+
+	// The child reports its files as they finish, under the submodule's path.
+	if d.onResult != nil {
+		opts.OnResult = func(fr FileResult) {
+			fr.Path = path.Join(file.Path, fr.Path)
+			d.onResult(fr)
+		}
+	}
+
+	client := d.client
+	if sub.Host.Name != client.host.Name {
+		opts.Client.Host = sub.Host
+		client = NewGitHubClientWithOptions(opts.Client)
+	}
+
+	child := NewDownloaderWithOptions(client, sub.Owner, sub.Repository, "", file.SHA, opts)
+	child.depth = d.depth + 1
+	child.parent = d
+
+	result, err := child.Download(ctx)
+	if result != nil {
+		for _, fr := range result.Files() {
+			fr.Path = path.Join(file.Path, fr.Path)
+			d.result.add(fr)
+		}
+	}
+
+	// Individual failures were merged above and count against this run.
+	if err != nil && !errors.Is(err, ErrIncomplete) {
+		return fmt.Errorf("submodule %s: %w", file.Path, err)
+	}
+	return nil
+}
+
+// submoduleURL returns where a submodule lives. The Contents API reports it
+// directly; the Trees API only has the commit, so .gitmodules is consulted.
+func (d *Downloader) submoduleURL(ctx context.Context, file remoteFile) (string, error) {
+	if file.SubmoduleURL != "" {
+		return file.SubmoduleURL, nil
+	}
+
+	d.gitmodulesOnce.Do(func() {
+		d.gitmodules, d.gitmodulesErr = d.readGitmodules(ctx)
+	})
+	if d.gitmodulesErr != nil {
+		return "", d.gitmodulesErr
+	}
+
+	raw, ok := d.gitmodules[file.Path]
+	if !ok {
+		return "", fmt.Errorf("submodule %s is not listed in .gitmodules", file.Path)
+	}
+
+	// Relative URLs are resolved against the superproject, as git does.
+	if strings.HasPrefix(raw, "./") || strings.HasPrefix(raw, "../") {
+		base, err := url.Parse(d.client.host.BaseURL + d.owner + "/" + d.repo + "/")
+		if err != nil {
+			return "", fmt.Errorf("invalid repository URL: %w", err)
+		}
+		ref, err := url.Parse(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid submodule URL %q: %w", raw, err)
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+
+	return raw, nil
+}
+
+// readGitmodules maps submodule paths to their URLs from the .gitmodules file
+// at the commit being downloaded.
+func (d *Downloader) readGitmodules(ctx context.Context) (map[string]string, error) {
+	if err := d.budget.list.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.budget.list.release()
+
+	opts := &github.RepositoryContentGetOptions{Ref: d.commitSHA}
+	fileContent, _, err := d.client.GetContents(ctx, d.owner, d.repo, ".gitmodules", opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitmodules: %w", err)
+	}
+
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode .gitmodules: %w", err)
+	}
+
+	return parseGitmodules(content), nil
+}
+
+func parseGitmodules(content string) map[string]string {
+	type entry struct{ path, url string }
+
+	var entries []*entry
+	var current *entry
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case strings.HasPrefix(line, "["):
+			current = nil
+			if strings.HasPrefix(line, "[submodule") {
+				current = &entry{}
+				entries = append(entries, current)
+			}
+			continue
+		case current == nil:
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.TrimSpace(key) {
+		case "path":
+			current.path = value
+		case "url":
+			current.url = value
+		}
+	}
+
+	urls := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.path != "" && e.url != "" {
+			urls[e.path] = e.url
+		}
+	}
+	return urls
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestName is the file sync writes into the output directory to remember
+// what it downloaded.
+const ManifestName = ".pgit-manifest.json"
+
+type Manifest struct {
+	Host       string         `json:"host,omitempty"` // empty for github.com
+	Owner      string         `json:"owner"`
+	Repository string         `json:"repository"`
+	Ref        string         `json:"ref,omitempty"`
+	Commit     string         `json:"commit,omitempty"`
+	Path       string         `json:"path,omitempty"`
+	Files      []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path      string `json:"path"`
+	SHA       string `json:"sha"`
+	LocalPath string `json:"local_path"` // relative to the output directory
+}
+
+// SyncResult is a download Result plus how the tree changed since the
+// previous sync.
+type SyncResult struct {
+	*Result
+
+	ManifestPath string
+	Previous     *Manifest
+	Added        []string
+	Modified     []string
+	Removed      []string
+	Unchanged    []string
+	// Kept lists removed files that were edited locally and so were left
+	// in place rather than deleted.
+	Kept []string
+}
+
+// ReadManifest loads a manifest, returning nil without error if there is none.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for manifest: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manifest) tracks(g *GitHubURL) bool {
+	return m.Host == manifestHost(g.Host) && m.Owner == g.Owner && m.Repository == g.Repository && m.Path == g.Path
+}
+
+func (m *Manifest) source() string {
+	if m.Host != "" {
+		return fmt.Sprintf("%s/%s/%s", m.Host, m.Owner, m.Repository)
+	}
+	return fmt.Sprintf("%s/%s", m.Owner, m.Repository)
+}
+
+func manifestHost(h Host) string {
+	if h.IsEnterprise() {
+		return h.Name
+	}
+	return ""
+}
+
+// Sync brings the output directory in line with the remote tree: changed
+// files are re-downloaded, unchanged ones are left alone, and files the
+// previous sync wrote that no longer exist upstream are deleted unless they
+// have been edited since.
+func (g *GitHubURL) Sync(ctx context.Context, opts Options) (*SyncResult, error) {
+	if opts.Mode == ModeArchive && opts.ListMode == ListContents {
+		return nil, fmt.Errorf("sync needs blob SHAs, which archive mode only has with the trees listing")
+	}
+
+	opts.Client.Host = g.Host
+	client := NewGitHubClientWithOptions(opts.Client)
+	if err := g.resolveRef(ctx, client); err != nil {
+		return nil, err
+	}
+
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	manifestPath := filepath.Join(outputDir, ManifestName)
+
+	previous, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && !previous.tracks(g) {
+		return nil, fmt.Errorf("%s tracks %s:%s, not %s:%s; sync into a different --output directory",
+			manifestPath, previous.source(), previous.Path, g.String(), g.Path)
+	}
+
+	opts.Conflict = ConflictUpdate
+	result, err := g.downloadWith(ctx, client, opts)
+	sync := &SyncResult{Result: result, ManifestPath: manifestPath, Previous: previous}
+	if err != nil {
+		return sync, err
+	}
+
+	current := &Manifest{
+		Host:       manifestHost(g.Host),
+		Owner:      g.Owner,
+		Repository: g.Repository,
+		Ref:        g.Branch,
+		Commit:     result.Commit,
+		Path:       g.Path,
+	}
+	for _, fr := range result.Files() {
+		// A fetched submodule's files are listed individually; the
+		// submodule directory itself is not something sync should delete.
+		if fr.LocalPath == "" || fr.Status == StatusFailed || fr.Type == "submodule" {
+			continue
+		}
+
+		localPath, err := filepath.Rel(outputDir, fr.LocalPath)
+		if err != nil {
+			return sync, fmt.Errorf("failed to record %s in manifest: %w", fr.Path, err)
+		}
+		current.Files = append(current.Files, ManifestFile{Path: fr.Path, SHA: fr.SHA, LocalPath: filepath.ToSlash(localPath)})
+	}
+	sort.Slice(current.Files, func(i, j int) bool { return current.Files[i].Path < current.Files[j].Path })
+
+	sync.diff(previous, current)
+
+	kept, err := removeStale(outputDir, previous, current)
+	sync.Kept = kept
+	if err != nil {
+		return sync, err
+	}
+
+	if err := current.Write(manifestPath); err != nil {
+		return sync, err
+	}
+
+	return sync, nil
+}
+
+func (s *SyncResult) diff(previous, current *Manifest) {
+	before := make(map[string]string)
+	if previous != nil {
+		for _, f := range previous.Files {
+			before[f.Path] = f.SHA
+		}
+	}
+
+	for _, f := range current.Files {
+		sha, ok := before[f.Path]
+		switch {
+		case !ok:
+			s.Added = append(s.Added, f.Path)
+		case sha != f.SHA:
+			s.Modified = append(s.Modified, f.Path)
+		default:
+			s.Unchanged = append(s.Unchanged, f.Path)
+		}
+		delete(before, f.Path)
+	}
+
+	for path := range before {
+		s.Removed = append(s.Removed, path)
+	}
+	sort.Strings(s.Removed)
+}
+
+// removeStale deletes files the previous manifest recorded that the current
+// one no longer has, then prunes directories that became empty. Only paths
+// pgit itself wrote are ever touched, and only while they still hold what was
+// written: like ConflictUpdate, a file whose blob SHA no longer matches the
+// manifest is treated as local work and kept. The kept paths are returned.
+func removeStale(outputDir string, previous, current *Manifest) ([]string, error) {
+	if previous == nil {
+		return nil, nil
+	}
+
+	keep := make(map[string]bool, len(current.Files))
+	for _, f := range current.Files {
+		keep[f.LocalPath] = true
+	}
+
+	var kept []string
+	for _, f := range previous.Files {
+		if keep[f.LocalPath] {
+			continue
+		}
+
+		rel := filepath.FromSlash(f.LocalPath)
+		if !filepath.IsLocal(rel) {
+			return kept, fmt.Errorf("refusing to delete %q outside the output directory", f.LocalPath)
+		}
+
+		localPath := filepath.Join(outputDir, rel)
+		modified, err := modifiedSince(localPath, f.SHA)
+		if err != nil {
+			return kept, err
+		}
+		if modified {
+			kept = append(kept, f.Path)
+			continue
+		}
+
+		if err := os.Remove(localPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return kept, fmt.Errorf("failed to delete %s: %w", localPath, err)
+		}
+
+		for dir := filepath.Dir(localPath); dir != filepath.Clean(outputDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+
+	return kept, nil
+}
+
+// modifiedSince reports whether the file at localPath no longer has the blob
+// SHA sync recorded for it. A missing file has nothing left to lose.
+func modifiedSince(localPath, sha string) (bool, error) {
+	info, err := os.Lstat(localPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", localPath, err)
+	}
+
+	var localSHA string
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(localPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to read symlink %s: %w", localPath, err)
+		}
+		localSHA = blobSHA([]byte(target))
+	case info.Mode().IsRegular():
+		localSHA, err = gitBlobSHA(localPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to hash %s: %w", localPath, err)
+		}
+	default:
+		return true, nil
+	}
+
+	return sha != "" && localSHA != sha, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSyncKeepsRemovedFilesEditedLocally(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{
+		"a.txt":      "alpha\n",
+		"edited.txt": "original\n",
+		"gone.txt":   "removed upstream\n",
+	})
+	dir := t.TempDir()
+	sync := func() *SyncResult {
+		t.Helper()
+		g, err := ParseGitHubURL(f.URL + "/" + testOwner + "/" + testRepo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := g.Sync(context.Background(), Options{Quiet: true, OutputDir: dir})
+		if err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		return result
+	}
+
+	sync()
+	edited := filepath.Join(dir, testRepo, "edited.txt")
+	if err := os.WriteFile(edited, []byte("local work\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	delete(f.files, "edited.txt")
+	delete(f.files, "gone.txt")
+
+	result := sync()
+	if !slices.Equal(result.Removed, []string{"edited.txt", "gone.txt"}) {
+		t.Errorf("Removed = %v", result.Removed)
+	}
+	if !slices.Equal(result.Kept, []string{"edited.txt"}) {
+		t.Errorf("Kept = %v, want [edited.txt]", result.Kept)
+	}
+	if got := readFile(t, dir, filepath.Join(testRepo, "edited.txt")); got != "local work\n" {
+		t.Errorf("edited.txt = %q, want the local edit", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, testRepo, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("gone.txt was not deleted")
+	}
+}
+
+func TestChangedFilesLeavesOutUpToDateFiles(t *testing.T) {
+	dir := t.TempDir()
+	d := NewDownloaderWithOptions(&GitHubClient{}, testOwner, testRepo, "", "main", Options{OutputDir: dir, Conflict: ConflictUpdate})
+	if err := os.MkdirAll(filepath.Join(dir, testRepo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, testRepo, "same.txt"), []byte("same\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []remoteFile{
+		{Path: "same.txt", Type: "file", SHA: blobSHA([]byte("same\n"))},
+		{Path: "new.txt", Type: "file", SHA: blobSHA([]byte("new\n"))},
+	}
+	if got := d.changedFiles(files); len(got) != 1 || got[0].Path != "new.txt" {
+		t.Errorf("changedFiles = %v, want just new.txt", got)
+	}
+}
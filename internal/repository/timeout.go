@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultStallTimeout   = 30 * time.Second
+)
+
+var ErrStalled = errors.New("no data received within the stall timeout")
+
+// stallBody cancels a transfer when the response body stops producing bytes
+// for longer than timeout. Slow but steady downloads are never interrupted.
+type stallBody struct {
+	body    io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (s *stallBody) Read(p []byte) (int, error) {
+	n, err := s.body.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	if err != nil && errors.Is(context.Cause(s.ctx), ErrStalled) {
+		err = ErrStalled
+	}
+	return n, err
+}
+
+func (s *stallBody) Close() error {
+	s.timer.Stop()
+	err := s.body.Close()
+	s.cancel(nil)
+	return err
+}
+
+// get sends req with the downloader's HTTP client. Waiting for headers is
+// bounded by the request timeout on the transport; once they arrive, the body
+// fails with ErrStalled if it goes quiet for longer than the stall timeout.
+func (d *Downloader) get(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if d.stallTimeout <= 0 {
+		return d.httpClient.Do(req.WithContext(ctx))
+	}
+
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	resp, err := d.httpClient.Do(req.WithContext(reqCtx))
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+
+	resp.Body = &stallBody{
+		body:    resp.Body,
+		ctx:     reqCtx,
+		cancel:  cancel,
+		timer:   time.AfterFunc(d.stallTimeout, func() { cancel(ErrStalled) }),
+		timeout: d.stallTimeout,
+	}
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// trickleServer sends count bytes, one every interval, then hangs until the
+// client goes away if hang is set.
+func trickleServer(t *testing.T, count int, interval time.Duration, hang bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < count; i++ {
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(interval)
+		}
+		if hang {
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func stallGet(t *testing.T, url string, requestTimeout, stallTimeout time.Duration) ([]byte, error) {
+	t.Helper()
+
+	d := &Downloader{httpClient: &http.Client{Transport: newTransport(requestTimeout)}, stallTimeout: stallTimeout}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := d.get(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func TestStallTimeoutStopsStalledBody(t *testing.T) {
+	server := trickleServer(t, 2, 0, true)
+
+	start := time.Now()
+	data, err := stallGet(t, server.URL, time.Second, 200*time.Millisecond)
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("err = %v, want ErrStalled", err)
+	}
+	if string(data) != "xx" {
+		t.Errorf("read %q before the stall, want %q", data, "xx")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stall noticed after %s", elapsed)
+	}
+}
+
+func TestStallTimeoutSparesSteadyBody(t *testing.T) {
+	// The whole body takes well over the stall timeout, but no gap does.
+	server := trickleServer(t, 10, 50*time.Millisecond, false)
+
+	data, err := stallGet(t, server.URL, time.Second, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("steady body failed: %v", err)
+	}
+	if len(data) != 10 {
+		t.Errorf("read %d bytes, want 10", len(data))
+	}
+}
+
+func TestRequestTimeoutWithoutHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	start := time.Now()
+	if _, err := stallGet(t, server.URL, 200*time.Millisecond, time.Minute); err == nil {
+		t.Fatal("request without headers succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request timed out after %s, want about 200ms", elapsed)
+	}
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	executableMode = "100755"
+	symlinkMode    = "120000"
+	submoduleMode  = "160000"
+)
+
+// ListMode selects how the Downloader discovers the files to fetch.
+type ListMode int
+
+const (
+	// ListTrees resolves the ref once and lists the whole subtree with a
+	// single recursive Git Trees call.
+	ListTrees ListMode = iota
+	// ListContents walks the subtree with one Contents API call per directory.
+	ListContents
+)
+
+func ParseListMode(s string) (ListMode, error) {
+	switch s {
+	case "", "trees":
+		return ListTrees, nil
+	case "contents":
+		return ListContents, nil
+	default:
+		return ListTrees, fmt.Errorf("unknown listing mode %q (expected trees or contents)", s)
+	}
+}
+
+func (m ListMode) String() string {
+	if m == ListContents {
+		return "contents"
+	}
+	return "trees"
+}
+
+// remoteFile is a file found by listing, whichever API produced it. Type uses
+// the Contents API vocabulary: file, dir, symlink or submodule. For a
+// submodule, SHA is the pinned commit rather than a blob.
+type remoteFile struct {
+	Path         string
+	SHA          string
+	Size         int
+	Mode         string
+	Type         string
+	DownloadURL  string
+	SubmoduleURL string
+}
+
+func (d *Downloader) downloadTree(ctx context.Context) {
+	listed, err := d.listTree(ctx)
+	if err != nil {
+		d.record(ctx, remoteFile{Path: d.basePath}, "", err)
+		return
+	}
+
+	var files []remoteFile
+	for _, file := range listed {
+		if d.wanted(ctx, file) {
+			files = append(files, file)
+		}
+	}
+
+	if !d.dryRun && d.useArchive(ctx, d.changedFiles(files)) {
+		d.listed = make(map[string]remoteFile, len(files))
+		for _, file := range files {
+			d.listed[file.Path] = file
+			if file.Type != "submodule" {
+				d.progress.expect(file)
+			}
+		}
+
+		if err := d.downloadArchive(ctx); err != nil {
+			d.record(ctx, remoteFile{Path: d.basePath}, "", err)
+			return
+		}
+
+		// Archives never contain submodule contents, so those still go
+		// through the file workers.
+		for _, file := range files {
+			if file.Type == "submodule" {
+				d.enqueueFile(file)
+			}
+		}
+		return
+	}
+
+	for _, file := range files {
+		if file.Type == "file" {
+			file.DownloadURL = d.rawURL(file.Path)
+		}
+		d.enqueueFile(file)
+	}
+}
+
+// changedFiles returns the files that would actually be written. Under
+// ConflictUpdate that leaves out those already up to date locally, so that a
+// sync with a handful of changes does not stream the whole archive for them.
+func (d *Downloader) changedFiles(files []remoteFile) []remoteFile {
+	if d.conflict != ConflictUpdate {
+		return files
+	}
+
+	var changed []remoteFile
+	for _, file := range files {
+		localPath, err := d.getExactPath(d.basePath, file.Path)
+		if err == nil && file.Type != "submodule" {
+			var skip *skipError
+			if errors.As(d.checkExisting(localPath, file), &skip) {
+				continue
+			}
+		}
+		changed = append(changed, file)
+	}
+	return changed
+}
+
+func (d *Downloader) listTree(ctx context.Context) ([]remoteFile, error) {
+	if err := d.budget.list.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.budget.list.release()
+
+	root, err := d.resolveRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Type != "dir" {
+		return []remoteFile{root}, nil
+	}
+
+	return d.walkTree(ctx, root.SHA, d.basePath)
+}
+
+// resolveRoot finds the tree (or single blob) that basePath points to. The
+// repository root is addressed by the commit itself; anything deeper takes one
+// Contents call on the path, whatever its depth. A directory's tree is then
+// addressed as "<commit>:<path>", which the Trees API accepts, so its
+// listing, capped at 1000 entries by the Contents API, is never needed.
+func (d *Downloader) resolveRoot(ctx context.Context) (remoteFile, error) {
+	if d.basePath == "" {
+		return remoteFile{Type: "dir", SHA: d.commitSHA}, nil
+	}
+
+	opts := &github.RepositoryContentGetOptions{Ref: d.commitSHA}
+	fileContent, _, err := d.client.GetContents(ctx, d.owner, d.repo, d.basePath, opts)
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return remoteFile{}, fmt.Errorf("path '%s' not found in %s/%s", d.basePath, d.owner, d.repo)
+	}
+	if err != nil {
+		return remoteFile{}, fmt.Errorf("failed to get contents for path '%s': %w", d.basePath, err)
+	}
+
+	if fileContent != nil {
+		return contentFile(fileContent), nil
+	}
+	return remoteFile{Path: d.basePath, Type: "dir", SHA: d.commitSHA + ":" + d.basePath}, nil
+}
+
+// walkTree lists every blob below the tree sha, prefixing paths with prefix.
+// GitHub truncates very large recursive listings; when that happens the tree
+// is walked one level at a time instead, retrying recursion on each subtree.
+func (d *Downloader) walkTree(ctx context.Context, sha, prefix string) ([]remoteFile, error) {
+	tree, err := d.client.GetTree(ctx, d.owner, d.repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for path '%s': %w", prefix, err)
+	}
+
+	if !tree.GetTruncated() {
+		return collectFiles(tree.Entries, prefix), nil
+	}
+
+	tree, err = d.client.GetTree(ctx, d.owner, d.repo, sha, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for path '%s': %w", prefix, err)
+	}
+
+	entries := collectFiles(tree.Entries, prefix)
+	for _, entry := range tree.Entries {
+		subPath := path.Join(prefix, entry.GetPath())
+		if entry.GetType() != "tree" || !d.filter.descends(d.relPath(subPath)) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		subEntries, err := d.walkTree(ctx, entry.GetSHA(), subPath)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, subEntries...)
+	}
+
+	return entries, nil
+}
+
+func collectFiles(entries []*github.TreeEntry, prefix string) []remoteFile {
+	var files []remoteFile
+	for _, entry := range entries {
+		file := remoteFile{
+			Path: path.Join(prefix, entry.GetPath()),
+			SHA:  entry.GetSHA(),
+			Size: entry.GetSize(),
+			Mode: entry.GetMode(),
+		}
+
+		switch {
+		case entry.GetType() == "commit":
+			file.Type = "submodule"
+		case entry.GetType() != "blob":
+			continue
+		case entry.GetMode() == symlinkMode:
+			file.Type = "symlink"
+		default:
+			file.Type = "file"
+		}
+
+		files = append(files, file)
+	}
+	return files
+}
+
+// rawURL builds the download URL for a file, pinned to the resolved commit.
+// github.com serves raw files from raw.githubusercontent.com. Enterprise
+// Server's raw host depends on whether subdomain isolation is enabled, so
+// there the Contents API is asked for the raw media type instead.
+func (d *Downloader) rawURL(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	if d.client.host.IsEnterprise() {
+		return fmt.Sprintf("%srepos/%s/%s/contents/%s?ref=%s",
+			d.client.host.APIURL(), d.owner, d.repo, strings.Join(segments, "/"), d.commitSHA)
+	}
+
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s",
+		d.owner, d.repo, d.commitSHA, strings.Join(segments, "/"))
+}
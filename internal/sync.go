@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"partial-git/internal/repository"
+	"partial-git/internal/token"
+	"slices"
+	"time"
+)
+
+func Sync(ctx context.Context, flags Flags, rawURL string) {
+	start := time.Now()
+	useAppAuth(flags)
+	githubURL, err := parseGitHubURL(rawURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing GitHub URL: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts, err := downloadOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	tokenManager := token.NewManagerForHost(githubURL.Host.Name)
+	if err := validateRuntimeConditions(ctx, flags, tokenManager, githubURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flags.Ref != "" {
+		githubURL.SetRef(flags.Ref)
+	}
+	if err := githubURL.ResolveRef(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flags.Format == FormatJSON {
+		result, err := githubURL.Sync(ctx, jsonOptions(opts, ""))
+		var summary summaryEvent
+		if result != nil {
+			summary = newSummaryEvent("summary", githubURL, result.Result, err)
+			summary.Added, summary.Modified, summary.Removed, summary.Kept = result.Added, result.Modified, result.Removed, result.Kept
+			summary.Manifest = result.ManifestPath
+		} else {
+			summary = newSummaryEvent("summary", githubURL, nil, err)
+		}
+		summary.OutputDir = flags.Output
+		summary.DurationMS = time.Since(start).Milliseconds()
+		printJSON(summary)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !flags.Quiet {
+		printDownloadHeader("sync", githubURL, flags)
+	}
+
+	result, err := githubURL.Sync(ctx, opts)
+	if result != nil && (!flags.Quiet || err != nil) {
+		printDownloadSummary(result.Result)
+	}
+	if err != nil {
+		exitOnDownloadError(err, flags)
+	}
+
+	if !flags.Quiet {
+		printSyncSummary(result)
+		fmt.Println("Sync Completed")
+		fmt.Println(time.Since(start))
+	}
+}
+
+func printSyncSummary(result *repository.SyncResult) {
+	if result.Previous == nil {
+		fmt.Printf("✓ Fetched %d files at %s\n", len(result.Added), shortSHA(result.Commit))
+	} else {
+		fmt.Printf("✓ Synced %s → %s\n", shortSHA(result.Previous.Commit), shortSHA(result.Commit))
+		for _, path := range result.Added {
+			fmt.Printf("  + %s\n", path)
+		}
+		for _, path := range result.Modified {
+			fmt.Printf("  ~ %s\n", path)
+		}
+		for _, path := range result.Removed {
+			if !slices.Contains(result.Kept, path) {
+				fmt.Printf("  - %s\n", path)
+			}
+		}
+		for _, path := range result.Kept {
+			fmt.Printf("  ! %s (removed upstream, kept because it was edited locally)\n", path)
+		}
+		fmt.Printf("Added: %d, Modified: %d, Removed: %d, Unchanged: %d\n",
+			len(result.Added), len(result.Modified), len(result.Removed), len(result.Unchanged))
+	}
+	fmt.Printf("Manifest: %s\n", result.ManifestPath)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	if sha == "" {
+		return "(unknown)"
+	}
+	return sha
+}
@@ -10,14 +10,62 @@ import (
 
 const envVarName = "PGIT_GITHUB_TOKEN"
 
-type EnvironmentStorage struct{}
+// EnvironmentStorage keeps a token in an environment variable exported from
+// the shell profile. github.com uses PGIT_GITHUB_TOKEN; each Enterprise
+// Server host gets its own variable, see EnvVarForHost.
+//
+// The profile is a plaintext file, so Manager only writes there when asked
+// to; a variable set any other way, as in CI, is always read.
+type EnvironmentStorage struct {
+	envVar string
+	host   string
+}
+
+func NewEnvironmentStorage(host string) *EnvironmentStorage {
+	return &EnvironmentStorage{envVar: EnvVarForHost(host), host: host}
+}
+
+func (e *EnvironmentStorage) Name() string {
+	if profilePath, err := e.getShellProfilePath(); err == nil && e.tokenExistsInProfile(profilePath) {
+		return fmt.Sprintf("environment variable %s (shell profile %s)", e.envVar, profilePath)
+	}
+	return "environment variable " + e.envVar
+}
+
+func (e *EnvironmentStorage) Available() bool {
+	return true
+}
+
+// EnvVarForHost names the variable holding the token for host, e.g.
+// PGIT_GITHUB_TOKEN_GHE_EXAMPLE_COM for ghe.example.com.
+func EnvVarForHost(host string) string {
+	if host == "" || host == "github.com" {
+		return envVarName
+	}
+
+	suffix := strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(host))
+	return envVarName + "_" + suffix
+}
+
+// profileMarker is the comment written above the export line.
+func (e *EnvironmentStorage) profileMarker() string {
+	if e.envVar == envVarName {
+		return "# PGIT GitHub token"
+	}
+	return "# PGIT GitHub token for " + e.host
+}
 
 func (e *EnvironmentStorage) Set(token string) error {
 	if token == "" {
 		return fmt.Errorf("token cannot be empty")
 	}
 
-	if err := os.Setenv(envVarName, token); err != nil {
+	if err := os.Setenv(e.envVar, token); err != nil {
 		return fmt.Errorf("failed to set environment variable: %w", err)
 	}
 
@@ -25,7 +73,7 @@ func (e *EnvironmentStorage) Set(token string) error {
 		fmt.Printf("⚠️  Warning: Failed to add to shell profile: %v\n", err)
 		fmt.Printf("✓ Token set for current session only.\n")
 		fmt.Printf("\n To make it permanent, manually add this line to your shell profile:\n")
-		fmt.Printf("   export %s=%s\n", envVarName, token)
+		fmt.Printf("   export %s=%s\n", e.envVar, token)
 		fmt.Printf("\n Then restart your terminal or run: source ~/.zshrc\n")
 		return nil
 	}
@@ -41,16 +89,16 @@ func (e *EnvironmentStorage) Set(token string) error {
 }
 
 func (e *EnvironmentStorage) Get() (string, error) {
-	token := os.Getenv(envVarName)
+	token := os.Getenv(e.envVar)
 	if token == "" {
-		return "", fmt.Errorf("GitHub token not found in environment variable %s", envVarName)
+		return "", fmt.Errorf("GitHub token not found in environment variable %s", e.envVar)
 	}
 
 	return token, nil
 }
 
 func (e *EnvironmentStorage) Delete() error {
-	if err := os.Unsetenv(envVarName); err != nil {
+	if err := os.Unsetenv(e.envVar); err != nil {
 		return fmt.Errorf("failed to unset environment variable: %w", err)
 	}
 
@@ -58,7 +106,7 @@ func (e *EnvironmentStorage) Delete() error {
 		fmt.Printf("⚠️  Warning: Failed to remove from shell profile: %v\n", err)
 		fmt.Printf("✓ Token removed from current session only.\n")
 		fmt.Printf("\nYou may need to manually remove this line from your shell profile:\n")
-		fmt.Printf("   export %s=...\n", envVarName)
+		fmt.Printf("   export %s=...\n", e.envVar)
 		fmt.Printf("\nThen restart your terminal or run: source ~/.zshrc\n")
 		return nil
 	}
@@ -71,7 +119,7 @@ func (e *EnvironmentStorage) Delete() error {
 }
 
 func (e *EnvironmentStorage) Exists() bool {
-	return os.Getenv(envVarName) != ""
+	return os.Getenv(e.envVar) != ""
 }
 
 func (e *EnvironmentStorage) getShellProfilePath() (string, error) {
@@ -123,7 +171,7 @@ func (e *EnvironmentStorage) addToShellProfile(token string) error {
 		}
 	}
 
-	exportLine := fmt.Sprintf("export %s=%s", envVarName, token)
+	exportLine := fmt.Sprintf("export %s=%s", e.envVar, token)
 
 	file, err := os.OpenFile(profilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -131,7 +179,7 @@ func (e *EnvironmentStorage) addToShellProfile(token string) error {
 	}
 	defer file.Close()
 
-	_, err = file.WriteString(fmt.Sprintf("\n# PGIT GitHub token\n%s\n", exportLine))
+	_, err = file.WriteString(fmt.Sprintf("\n%s\n%s\n", e.profileMarker(), exportLine))
 	if err != nil {
 		return fmt.Errorf("failed to write to shell profile: %w", err)
 	}
@@ -162,17 +210,17 @@ func (e *EnvironmentStorage) removeFromShellProfile() error {
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		if strings.Contains(line, "# PGIT GitHub token") {
+		if strings.TrimSpace(line) == e.profileMarker() {
 			skipNext = true
 			continue
 		}
 
-		if skipNext && strings.HasPrefix(strings.TrimSpace(line), fmt.Sprintf("export %s=", envVarName)) {
+		if skipNext && strings.HasPrefix(strings.TrimSpace(line), fmt.Sprintf("export %s=", e.envVar)) {
 			skipNext = false
 			continue
 		}
 
-		if strings.HasPrefix(strings.TrimSpace(line), fmt.Sprintf("export %s=", envVarName)) {
+		if strings.HasPrefix(strings.TrimSpace(line), fmt.Sprintf("export %s=", e.envVar)) {
 			continue
 		}
 
@@ -202,7 +250,7 @@ func (e *EnvironmentStorage) tokenExistsInProfile(profilePath string) bool {
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, fmt.Sprintf("export %s=", envVarName)) {
+		if strings.HasPrefix(line, fmt.Sprintf("export %s=", e.envVar)) {
 			return true
 		}
 	}
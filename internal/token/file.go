@@ -0,0 +1,316 @@
+package token
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// PassphraseEnv supplies the file backend's passphrase without a prompt,
+	// e.g. in CI.
+	PassphraseEnv = "PGIT_TOKEN_PASSPHRASE"
+
+	pbkdf2Iterations = 600_000
+	tokensFileName   = "tokens.json"
+)
+
+// FileStorage keeps tokens in $XDG_CONFIG_HOME/pgit/tokens.json (or the
+// platform's equivalent), each encrypted with AES-256-GCM under a key derived
+// from a passphrase. The file is readable only by its owner.
+type FileStorage struct {
+	host string
+	path string
+}
+
+func NewFileStorage(host string) *FileStorage {
+	path := ""
+	if dir, err := os.UserConfigDir(); err == nil {
+		path = filepath.Join(dir, "pgit", tokensFileName)
+	}
+	return &FileStorage{host: host, path: path}
+}
+
+type tokensFile struct {
+	Version int                       `json:"version"`
+	Tokens  map[string]encryptedToken `json:"tokens"`
+}
+
+// encryptedToken holds one host's token. Each has its own salt, so the same
+// passphrase yields a different key per entry.
+type encryptedToken struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+var (
+	// The passphrase is asked for at most once per run, and each token
+	// decrypted at most once, since key derivation is deliberately slow.
+	passphraseMu    sync.Mutex
+	passphrase      string
+	decryptedTokens sync.Map // path + host -> token
+)
+
+func (f *FileStorage) Name() string {
+	return "encrypted file (" + f.path + ")"
+}
+
+func (f *FileStorage) Available() bool {
+	return f.path != ""
+}
+
+func (f *FileStorage) Set(token string) error {
+	if token == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+	if !f.Available() {
+		return fmt.Errorf("no user config directory to store tokens in")
+	}
+
+	tokens, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	secret, err := getPassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	entry, err := encryptToken(token, secret)
+	if err != nil {
+		return err
+	}
+	tokens.Tokens[f.host] = entry
+
+	if err := f.write(tokens); err != nil {
+		return err
+	}
+
+	decryptedTokens.Store(f.path+"\x00"+f.host, token)
+	fmt.Printf("✓ Token stored in %s\n", f.Name())
+	return nil
+}
+
+func (f *FileStorage) Get() (string, error) {
+	if token, ok := decryptedTokens.Load(f.path + "\x00" + f.host); ok {
+		return token.(string), nil
+	}
+
+	tokens, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	entry, ok := tokens.Tokens[f.host]
+	if !ok {
+		return "", fmt.Errorf("GitHub token for %s not found in %s", f.host, f.path)
+	}
+
+	secret, err := getPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := decryptToken(entry, secret)
+	if err != nil {
+		return "", err
+	}
+
+	decryptedTokens.Store(f.path+"\x00"+f.host, token)
+	return token, nil
+}
+
+func (f *FileStorage) Delete() error {
+	tokens, err := f.read()
+	if err != nil {
+		return err
+	}
+	delete(tokens.Tokens, f.host)
+
+	if err := f.write(tokens); err != nil {
+		return err
+	}
+
+	decryptedTokens.Delete(f.path + "\x00" + f.host)
+	fmt.Printf("✓ Token removed from %s\n", f.Name())
+	return nil
+}
+
+// Exists checks for an entry without decrypting it, so it never prompts.
+func (f *FileStorage) Exists() bool {
+	if !f.Available() {
+		return false
+	}
+	tokens, err := f.read()
+	if err != nil {
+		return false
+	}
+	_, ok := tokens.Tokens[f.host]
+	return ok
+}
+
+func (f *FileStorage) read() (*tokensFile, error) {
+	tokens := &tokensFile{Version: 1, Tokens: make(map[string]encryptedToken)}
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", f.path, err)
+	}
+	if tokens.Tokens == nil {
+		tokens.Tokens = make(map[string]encryptedToken)
+	}
+	return tokens, nil
+}
+
+func (f *FileStorage) write(tokens *tokensFile) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token file: %w", err)
+	}
+
+	// Write a sibling and rename it into place so a crash never leaves a
+	// half-written file behind.
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	return nil
+}
+
+func encryptToken(token, secret string) (encryptedToken, error) {
+	entry := encryptedToken{KDF: "pbkdf2-sha256", Iterations: pbkdf2Iterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(entry.Salt); err != nil {
+		return entry, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := newGCM(secret, entry)
+	if err != nil {
+		return entry, err
+	}
+
+	entry.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(entry.Nonce); err != nil {
+		return entry, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	entry.Ciphertext = gcm.Seal(nil, entry.Nonce, []byte(token), nil)
+	return entry, nil
+}
+
+func decryptToken(entry encryptedToken, secret string) (string, error) {
+	if entry.KDF != "pbkdf2-sha256" {
+		return "", fmt.Errorf("unsupported key derivation %q in token file", entry.KDF)
+	}
+
+	gcm, err := newGCM(secret, entry)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := gcm.Open(nil, entry.Nonce, entry.Ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: wrong passphrase or corrupted file")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(secret string, entry encryptedToken) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(secret), entry.Salt, entry.Iterations, 32, sha256.New))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// getPassphrase returns the passphrase from PGIT_TOKEN_PASSPHRASE, or asks
+// for it on the terminal, twice when it is about to encrypt something.
+func getPassphrase(confirm bool) (string, error) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	if env := os.Getenv(PassphraseEnv); env != "" {
+		passphrase = env
+		return passphrase, nil
+	}
+
+	secret, err := promptPassphrase("Token file passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", fmt.Errorf("passphrase cannot be empty")
+	}
+	if confirm {
+		again, err := promptPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != secret {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	passphrase = secret
+	return passphrase, nil
+}
+
+// promptPassphrase reads a line from the controlling terminal with echo
+// turned off.
+func promptPassphrase(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the token file passphrase; set %s", PassphraseEnv)
+	}
+	defer tty.Close()
+
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err != nil {
+		return "", fmt.Errorf("failed to disable terminal echo; set %s instead: %w", PassphraseEnv, err)
+	}
+	defer stty("echo")
+
+	fmt.Fprint(tty, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,94 @@
+package token
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usePassphrase sets the passphrase for the test and forgets anything an
+// earlier test cached.
+func usePassphrase(t *testing.T, secret string) {
+	t.Setenv(PassphraseEnv, secret)
+
+	passphraseMu.Lock()
+	passphrase = ""
+	passphraseMu.Unlock()
+	decryptedTokens.Clear()
+}
+
+func TestFileStorageRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgit", tokensFileName)
+	usePassphrase(t, "correct horse")
+
+	dotcom := &FileStorage{host: "github.com", path: path}
+	enterprise := &FileStorage{host: "ghe.example.com", path: path}
+	if dotcom.Exists() {
+		t.Fatal("Exists before anything was stored")
+	}
+	if err := dotcom.Set(classicToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := enterprise.Set(otherToken); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), classicToken) || strings.Contains(string(data), otherToken) {
+		t.Error("token file holds a token in plaintext")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("token file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	// Decrypt from the file rather than the cache.
+	usePassphrase(t, "correct horse")
+	if got, err := (&FileStorage{host: "github.com", path: path}).Get(); err != nil || got != classicToken {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if got, err := enterprise.Get(); err != nil || got != otherToken {
+		t.Errorf("Get for the second host = %q, %v", got, err)
+	}
+
+	usePassphrase(t, "wrong")
+	if _, err := dotcom.Get(); err == nil {
+		t.Error("Get succeeded with the wrong passphrase")
+	}
+
+	if err := dotcom.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if dotcom.Exists() || !enterprise.Exists() {
+		t.Errorf("after Delete: github.com exists %v, ghe.example.com exists %v", dotcom.Exists(), enterprise.Exists())
+	}
+}
+
+// TestDecryptTokenFixture guards the key derivation: files written by earlier
+// versions must keep decrypting.
+func TestDecryptTokenFixture(t *testing.T) {
+	var entry encryptedToken
+	fixture := `{
+		"kdf": "pbkdf2-sha256",
+		"iterations": 1000,
+		"salt": "MDEyMzQ1Njc4OWFiY2RlZg==",
+		"nonce": "bm9uY2UtMTJieXRl",
+		"ciphertext": "rI/Ns8SA66TJRknPGkgA21U0seJdpKisbo/vAP+c/tEHeJp9ukwoWeFwQIjIn37lXqAXITKYCBlvPg=="
+	}`
+	if err := json.Unmarshal([]byte(fixture), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := decryptToken(entry, "correct horse"); err != nil || got != classicToken {
+		t.Errorf("decryptToken = %q, %v; want %q", got, err, classicToken)
+	}
+
+	entry.KDF = "scrypt"
+	if _, err := decryptToken(entry, "correct horse"); err == nil {
+		t.Error("decryptToken accepted an unknown key derivation")
+	}
+}
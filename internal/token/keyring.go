@@ -0,0 +1,138 @@
+package token
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const keyringService = "pgit"
+
+// KeyringStorage keeps the token in the operating system's keyring: the
+// Secret Service (GNOME Keyring, KWallet) through secret-tool on Linux, and
+// the login keychain through security on macOS.
+type KeyringStorage struct {
+	host string
+}
+
+func NewKeyringStorage(host string) *KeyringStorage {
+	return &KeyringStorage{host: host}
+}
+
+// keyringCache saves a process spawn for every lookup; a run asks for the
+// token each time it creates an API client.
+var keyringCache sync.Map // host -> token
+
+func (k *KeyringStorage) Name() string {
+	if runtime.GOOS == "darwin" {
+		return "macOS keychain"
+	}
+	return "system keyring (Secret Service)"
+}
+
+func (k *KeyringStorage) Available() bool {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := exec.LookPath("security")
+		return err == nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		_, err := exec.LookPath("secret-tool")
+		return err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+	default:
+		return false
+	}
+}
+
+func (k *KeyringStorage) Set(token string) error {
+	if token == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// -U updates an existing item. security only takes the password as
+		// an argument, where it is briefly visible to other local users.
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", k.host, "-w", token)
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", "pgit GitHub token for "+k.host,
+			"service", keyringService, "host", k.host)
+		cmd.Stdin = strings.NewReader(token)
+	}
+
+	if err := run(cmd); err != nil {
+		return fmt.Errorf("failed to store token in %s: %w", k.Name(), err)
+	}
+
+	keyringCache.Store(k.host, token)
+	fmt.Printf("✓ Token stored in %s\n", k.Name())
+	return nil
+}
+
+func (k *KeyringStorage) Get() (string, error) {
+	if token, ok := keyringCache.Load(k.host); ok {
+		return token.(string), nil
+	}
+	if !k.Available() {
+		return "", fmt.Errorf("%s is not available", k.Name())
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", k.host, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "host", k.host)
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := run(cmd); err != nil {
+		return "", fmt.Errorf("GitHub token for %s not found in %s", k.host, k.Name())
+	}
+
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("GitHub token for %s not found in %s", k.host, k.Name())
+	}
+
+	keyringCache.Store(k.host, token)
+	return token, nil
+}
+
+func (k *KeyringStorage) Delete() error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", k.host)
+	} else {
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "host", k.host)
+	}
+
+	if err := run(cmd); err != nil {
+		return fmt.Errorf("failed to remove token from %s: %w", k.Name(), err)
+	}
+
+	keyringCache.Delete(k.host)
+	fmt.Printf("✓ Token removed from %s\n", k.Name())
+	return nil
+}
+
+func (k *KeyringStorage) Exists() bool {
+	_, err := k.Get()
+	return err == nil
+}
+
+// run runs cmd, folding its stderr into the error.
+func run(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return errors.New(msg)
+	}
+	return err
+}
@@ -7,11 +7,97 @@ import (
 )
 
 type Manager struct {
-	storage *EnvironmentStorage
+	host string
+	// sources are searched in order for a token. The environment comes
+	// first, so a variable set for one command overrides the rest, and
+	// pgit's own storage comes before credentials borrowed from other tools.
+	sources []CredentialSource
+	// storage is the kind SetToken writes to; empty picks the most secure
+	// one available.
+	storage string
 }
 
 func NewManager() *Manager {
-	return &Manager{storage: &EnvironmentStorage{}}
+	return NewManagerForHost("github.com")
+}
+
+// NewManagerForHost manages the token used for one GitHub host, so github.com
+// and each Enterprise Server instance can have their own.
+func NewManagerForHost(host string) *Manager {
+	return NewManagerWithStorage(host,
+		NewEnvironmentStorage(host),
+		NewEnvSource(host),
+		NewKeyringStorage(host),
+		NewFileStorage(host),
+		NewGHConfigSource(host),
+		NewGitCredentialSource(host),
+		NewNetrcSource(host),
+	)
+}
+
+// NewManagerWithStorage manages a token found in the given sources, listed
+// in lookup order. Those that are also TokenStorage can be written to.
+func NewManagerWithStorage(host string, sources ...CredentialSource) *Manager {
+	return &Manager{host: host, sources: sources}
+}
+
+// UseStorage makes SetToken write to one kind of backend: keyring, file, or
+// profile for the legacy plaintext shell profile.
+func (m *Manager) UseStorage(kind string) error {
+	kind, err := parseStorageKind(kind)
+	if err != nil {
+		return err
+	}
+	m.storage = kind
+	return nil
+}
+
+// setStorage picks where SetToken writes: the backend chosen with
+// UseStorage, or else the keyring, then the encrypted file. The shell
+// profile is never chosen automatically.
+func (m *Manager) setStorage() (TokenStorage, error) {
+	if m.storage != "" {
+		for _, s := range m.backends() {
+			if storageKind(s) != m.storage {
+				continue
+			}
+			if !s.Available() {
+				return nil, fmt.Errorf("%s is not available on this machine", s.Name())
+			}
+			return s, nil
+		}
+		return nil, fmt.Errorf("%s token storage is not configured", m.storage)
+	}
+
+	for _, kind := range []string{StorageKeyring, StorageFile, ""} {
+		for _, s := range m.backends() {
+			if storageKind(s) == kind && s.Available() {
+				return s, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no secure token storage is available; use --storage profile to keep the token in your shell profile")
+}
+
+// backends returns the sources pgit can write to.
+func (m *Manager) backends() []TokenStorage {
+	var backends []TokenStorage
+	for _, s := range m.sources {
+		if storage, ok := s.(TokenStorage); ok {
+			backends = append(backends, storage)
+		}
+	}
+	return backends
+}
+
+// stored returns the source the token would be read from, or nil.
+func (m *Manager) stored() CredentialSource {
+	for _, s := range m.sources {
+		if s.Exists() {
+			return s
+		}
+	}
+	return nil
 }
 
 func (m *Manager) SetToken(token string) error {
@@ -19,7 +105,12 @@ func (m *Manager) SetToken(token string) error {
 		return fmt.Errorf("invalid token: %w", err)
 	}
 
-	if err := m.storage.Set(token); err != nil {
+	storage, err := m.setStorage()
+	if err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+
+	if err := storage.Set(token); err != nil {
 		return fmt.Errorf("failed to store token: %w", err)
 	}
 
@@ -27,7 +118,12 @@ func (m *Manager) SetToken(token string) error {
 }
 
 func (m *Manager) GetToken() (string, error) {
-	token, err := m.storage.Get()
+	storage := m.stored()
+	if storage == nil {
+		return "", fmt.Errorf("failed to retrieve token: GitHub token for %s not found", m.host)
+	}
+
+	token, err := storage.Get()
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve token: %w", err)
 	}
@@ -35,21 +131,37 @@ func (m *Manager) GetToken() (string, error) {
 	return token, nil
 }
 
-func (m *Manager) DeleteToken() error {
-	if !m.storage.Exists() {
-		fmt.Println("No GitHub token found to delete")
-		return nil
+// StoredToken returns the token from pgit's own storage, ignoring
+// credentials borrowed from other tools.
+func (m *Manager) StoredToken() (string, error) {
+	for _, s := range m.backends() {
+		if s.Exists() {
+			return s.Get()
+		}
 	}
+	return "", fmt.Errorf("no GitHub token for %s is stored by pgit", m.host)
+}
 
-	if err := m.storage.Delete(); err != nil {
-		return fmt.Errorf("failed to delete token: %w", err)
+// DeleteToken removes the token from every backend holding one, so that an
+// older copy cannot take over once the newest is gone, and reports whether
+// there was one. Credentials belonging to other tools are left alone; check
+// TokenExists afterwards to warn about them.
+func (m *Manager) DeleteToken() (bool, error) {
+	deleted := false
+	for _, s := range m.backends() {
+		if !s.Exists() {
+			continue
+		}
+		if err := s.Delete(); err != nil {
+			return deleted, fmt.Errorf("failed to delete token: %w", err)
+		}
+		deleted = true
 	}
-
-	return nil
+	return deleted, nil
 }
 
 func (m *Manager) TokenExists() bool {
-	return m.storage.Exists()
+	return m.stored() != nil
 }
 
 func (m *Manager) validateToken(token string) error {
@@ -90,8 +202,68 @@ func (m *Manager) validateToken(token string) error {
 	return nil
 }
 
+// GetStorageInfo names the backend the token is read from or, when there is
+// none yet, the one SetToken would write to.
 func (m *Manager) GetStorageInfo() string {
-	return "Shell Profile (zsh/bash)"
+	if storage := m.stored(); storage != nil {
+		return storage.Name()
+	}
+	if storage, err := m.setStorage(); err == nil {
+		return storage.Name() + " (empty)"
+	}
+	return "none available"
+}
+
+// SourceStatus describes one place the token was looked for.
+type SourceStatus struct {
+	Name string
+	// Found reports whether the source has a token for the host.
+	Found bool
+	// Used marks the source the token is actually read from.
+	Used bool
+}
+
+// Sources reports every source in lookup order, for --check.
+func (m *Manager) Sources() []SourceStatus {
+	statuses := make([]SourceStatus, 0, len(m.sources))
+	used := false
+	for _, s := range m.sources {
+		status := SourceStatus{Name: s.Name(), Found: s.Exists()}
+		status.Used = status.Found && !used
+		used = used || status.Found
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Kinds of token, told apart by their prefix.
+const (
+	KindClassic         = "classic"
+	KindFineGrained     = "fine-grained"
+	KindOAuth           = "oauth"
+	KindAppUser         = "app-user"
+	KindAppInstallation = "app-installation"
+	KindUnknown         = "unknown"
+)
+
+// Kind reports what sort of token this is. Fine-grained tokens have no OAuth
+// scopes; their access is limited to the repositories chosen when they were
+// created.
+func Kind(token string) string {
+	switch {
+	case strings.HasPrefix(token, "github_pat_"):
+		return KindFineGrained
+	case strings.HasPrefix(token, "ghp_"):
+		return KindClassic
+	case strings.HasPrefix(token, "gho_"):
+		return KindOAuth
+	case strings.HasPrefix(token, "ghu_"):
+		return KindAppUser
+	case strings.HasPrefix(token, "ghs_"):
+		return KindAppInstallation
+	default:
+		return KindUnknown
+	}
 }
 
 func ValidateToken(token string) error {
@@ -0,0 +1,321 @@
+package token
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lookups remembers the outcome of each external lookup for the rest of the
+// run. They read files or start processes, and a run asks for the token every
+// time it creates an API client.
+var lookups sync.Map // key -> lookupResult
+
+type lookupResult struct {
+	token string
+	err   error
+}
+
+func lookupOnce(key string, lookup func() (string, error)) (string, error) {
+	if cached, ok := lookups.Load(key); ok {
+		result := cached.(lookupResult)
+		return result.token, result.err
+	}
+
+	token, err := lookup()
+	if err == nil && token == "" {
+		err = fmt.Errorf("no token")
+	}
+	lookups.Store(key, lookupResult{token: token, err: err})
+	return token, err
+}
+
+// EnvSource reads the variables the gh CLI and GitHub Actions use:
+// GH_TOKEN and GITHUB_TOKEN for github.com, GH_ENTERPRISE_TOKEN and
+// GITHUB_ENTERPRISE_TOKEN for any other host.
+type EnvSource struct {
+	vars []string
+}
+
+func NewEnvSource(host string) *EnvSource {
+	if host == "" || host == "github.com" {
+		return &EnvSource{vars: []string{"GH_TOKEN", "GITHUB_TOKEN"}}
+	}
+	return &EnvSource{vars: []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}}
+}
+
+func (e *EnvSource) Name() string {
+	for _, name := range e.vars {
+		if os.Getenv(name) != "" {
+			return "environment variable " + name
+		}
+	}
+	return "environment variable " + strings.Join(e.vars, " or ")
+}
+
+func (e *EnvSource) Get() (string, error) {
+	for _, name := range e.vars {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s is set", strings.Join(e.vars, ", "))
+}
+
+func (e *EnvSource) Exists() bool {
+	_, err := e.Get()
+	return err == nil
+}
+
+// GHConfigSource reads the token the gh CLI stored for the host, from
+// hosts.yml or, for newer versions that keep it in the keyring, from
+// `gh auth token`.
+type GHConfigSource struct {
+	host string
+}
+
+func NewGHConfigSource(host string) *GHConfigSource {
+	return &GHConfigSource{host: host}
+}
+
+func (g *GHConfigSource) Name() string {
+	return "gh CLI (" + ghHostsPath() + ")"
+}
+
+func (g *GHConfigSource) Get() (string, error) {
+	return lookupOnce("gh\x00"+g.host, func() (string, error) {
+		data, err := os.ReadFile(ghHostsPath())
+		if err != nil {
+			return "", fmt.Errorf("failed to read gh config: %w", err)
+		}
+
+		token, listed := parseGHHosts(data, g.host)
+		if token != "" || !listed {
+			return token, nil
+		}
+
+		if _, err := exec.LookPath("gh"); err != nil {
+			return "", nil
+		}
+		var stdout bytes.Buffer
+		cmd := exec.Command("gh", "auth", "token", "--hostname", g.host)
+		cmd.Stdout = &stdout
+		if err := run(cmd); err != nil {
+			return "", fmt.Errorf("gh auth token: %w", err)
+		}
+		return strings.TrimSpace(stdout.String()), nil
+	})
+}
+
+func (g *GHConfigSource) Exists() bool {
+	_, err := g.Get()
+	return err == nil
+}
+
+func ghHostsPath() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// parseGHHosts finds host's oauth_token in gh's hosts.yml, which maps each
+// host to its settings:
+//
+//	github.com:
+//	    user: octocat
+//	    oauth_token: gho_...
+//
+// It also reports whether the host is listed at all. Only this shape of YAML
+// is understood, which is all gh writes.
+func parseGHHosts(data []byte, host string) (token string, listed bool) {
+	childIndent := -1
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+
+		if indent == 0 {
+			if listed {
+				break // the next host
+			}
+			listed = unquote(strings.TrimSuffix(trimmed, ":")) == host
+			continue
+		}
+		if !listed {
+			continue
+		}
+
+		// Nested entries, such as the per-user copies under users:, are
+		// indented further than the host's own keys.
+		if childIndent < 0 {
+			childIndent = indent
+		}
+		if key, value, ok := strings.Cut(trimmed, ":"); ok && indent == childIndent && key == "oauth_token" {
+			return unquote(strings.TrimSpace(value)), true
+		}
+	}
+	return "", listed
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// GitCredentialSource asks git's configured credential helpers for the
+// host's HTTPS password, which for GitHub is a token.
+type GitCredentialSource struct {
+	host string
+}
+
+func NewGitCredentialSource(host string) *GitCredentialSource {
+	return &GitCredentialSource{host: host}
+}
+
+func (g *GitCredentialSource) Name() string {
+	return "git credential helper"
+}
+
+func (g *GitCredentialSource) Get() (string, error) {
+	return lookupOnce("git\x00"+g.host, func() (string, error) {
+		if _, err := exec.LookPath("git"); err != nil {
+			return "", err
+		}
+
+		// A helper that would have to ask the user is no use here.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "GIT_ASKPASS=")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", g.host))
+
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		if err := run(cmd); err != nil {
+			return "", fmt.Errorf("git credential fill: %w", err)
+		}
+
+		for _, line := range strings.Split(stdout.String(), "\n") {
+			if password, ok := strings.CutPrefix(line, "password="); ok {
+				return password, nil
+			}
+		}
+		return "", nil
+	})
+}
+
+func (g *GitCredentialSource) Exists() bool {
+	_, err := g.Get()
+	return err == nil
+}
+
+// NetrcSource reads the password for the host, or for its api. subdomain,
+// from $NETRC or ~/.netrc.
+type NetrcSource struct {
+	host string
+}
+
+func NewNetrcSource(host string) *NetrcSource {
+	return &NetrcSource{host: host}
+}
+
+func (n *NetrcSource) Name() string {
+	return "netrc (" + netrcPath() + ")"
+}
+
+func (n *NetrcSource) Get() (string, error) {
+	return lookupOnce("netrc\x00"+n.host, func() (string, error) {
+		data, err := os.ReadFile(netrcPath())
+		if err != nil {
+			return "", fmt.Errorf("failed to read netrc: %w", err)
+		}
+		return parseNetrc(string(data), n.host, "api."+n.host), nil
+	})
+}
+
+func (n *NetrcSource) Exists() bool {
+	_, err := n.Get()
+	return err == nil
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, _ := os.UserHomeDir()
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// parseNetrc returns the password of the first machine entry naming one of
+// machines. The default entry is ignored: it is meant for some other server.
+func parseNetrc(data string, machines ...string) string {
+	fields := netrcFields(data)
+	var machine string
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				machine = fields[i]
+			}
+		case "default":
+			machine = ""
+		case "password":
+			if i+1 >= len(fields) {
+				return ""
+			}
+			i++
+			for _, want := range machines {
+				if machine == want {
+					return fields[i]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// netrcFields splits a netrc file into tokens, leaving out macro
+// definitions: a macdef runs from the line after it to the next blank line.
+func netrcFields(data string) []string {
+	var fields []string
+	inMacro := false
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		lineFields := strings.Fields(line)
+		if i := slices.Index(lineFields, "macdef"); i >= 0 {
+			lineFields, inMacro = lineFields[:i], true
+		}
+		fields = append(fields, lineFields...)
+	}
+	return fields
+}
@@ -0,0 +1,95 @@
+package token
+
+import "testing"
+
+func TestParseGHHosts(t *testing.T) {
+	const hosts = `github.com:
+    user: octocat
+    oauth_token: gho_dotcom
+    git_protocol: https
+    users:
+        octocat:
+            oauth_token: gho_nested
+"ghe.example.com":
+    oauth_token: "gho_quoted"
+    user: 'monalisa'
+ghe.single.com:
+    oauth_token: 'gho_single'
+# a comment
+keyring.example.com:
+    user: hubot
+    git_protocol: ssh
+`
+	tests := []struct {
+		host       string
+		wantToken  string
+		wantListed bool
+	}{
+		{"github.com", "gho_dotcom", true},
+		{"ghe.example.com", "gho_quoted", true},
+		{"ghe.single.com", "gho_single", true},
+		// gh keeps the token in the system keyring; the host is listed
+		// without one.
+		{"keyring.example.com", "", true},
+		{"missing.example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			token, listed := parseGHHosts([]byte(hosts), tt.host)
+			if token != tt.wantToken || listed != tt.wantListed {
+				t.Errorf("parseGHHosts(%s) = %q, %v, want %q, %v", tt.host, token, listed, tt.wantToken, tt.wantListed)
+			}
+		})
+	}
+}
+
+func TestParseGHHostsNestedTokenOnly(t *testing.T) {
+	// Only the per-user copy has a token; it is not the host's own.
+	const hosts = `github.com:
+    users:
+        octocat:
+            oauth_token: gho_nested
+    user: octocat
+`
+	if token, listed := parseGHHosts([]byte(hosts), "github.com"); token != "" || !listed {
+		t.Errorf("parseGHHosts = %q, %v, want no token and listed", token, listed)
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name  string
+		netrc string
+		want  string
+	}{
+		{"one line", "machine github.com login octocat password ghp_one", "ghp_one"},
+		{"several lines", "machine github.com\n  login octocat\n  password ghp_lines\n", "ghp_lines"},
+		{"api subdomain", "machine api.github.com login octocat password ghp_api", "ghp_api"},
+		{"first entry wins", "machine api.github.com password ghp_api\nmachine github.com password ghp_host", "ghp_api"},
+		{"other machine", "machine gitlab.com login x password glpat", ""},
+		{"default ignored", "default login anonymous password guest", ""},
+		{"default after machine", "machine github.com password ghp_before\ndefault password guest", "ghp_before"},
+		{"machine after default", "default password guest\nmachine github.com password ghp_after", "ghp_after"},
+		{"password before login", "machine github.com password ghp_first login octocat", "ghp_first"},
+		{"missing password value", "machine github.com password", ""},
+		{
+			"macro skipped",
+			"macdef init\nmachine github.com password ghp_macro\n\nmachine github.com password ghp_real\n",
+			"ghp_real",
+		},
+		{
+			"macro after entry",
+			"machine github.com password ghp_real\nmacdef init\ncd /pub\n\n",
+			"ghp_real",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNetrc(tt.netrc, "github.com", "api.github.com"); got != tt.want {
+				t.Errorf("parseNetrc = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CredentialSource is somewhere the token for one GitHub host can be found.
+type CredentialSource interface {
+	Get() (string, error)
+	Exists() bool
+	// Name describes where the token lives, for --set and --check output.
+	Name() string
+}
+
+// TokenStorage is a CredentialSource pgit can also write to.
+type TokenStorage interface {
+	CredentialSource
+	Set(token string) error
+	Delete() error
+	// Available reports whether the backend can be used on this machine.
+	Available() bool
+}
+
+// Storage kinds accepted by Manager.UseStorage and --storage.
+const (
+	StorageKeyring = "keyring"
+	StorageFile    = "file"
+	StorageProfile = "profile"
+)
+
+// storageKind maps a backend back to the name --storage selects it by.
+func storageKind(s CredentialSource) string {
+	switch s.(type) {
+	case *KeyringStorage:
+		return StorageKeyring
+	case *FileStorage:
+		return StorageFile
+	case *EnvironmentStorage:
+		return StorageProfile
+	default:
+		return ""
+	}
+}
+
+func parseStorageKind(kind string) (string, error) {
+	switch kind = strings.ToLower(kind); kind {
+	case StorageKeyring, StorageFile, StorageProfile:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown token storage %q (expected keyring, file or profile)", kind)
+	}
+}